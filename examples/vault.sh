@@ -45,20 +45,18 @@ export VAULT_TOKEN=$token
 vault plugin register -sha256="$(sha256sum "$bin/nats" | cut -d ' ' -f1)" secret nats
 vault secrets enable nats
 
-vault write -force nats/accounts/system 2>&1 > /dev/null
-
 echo -e "\033[0;34m
 This shell is configured for the vault example server:
 
 export VAULT_TOKEN=$token
 export VAULT_ADDR=http://localhost:8200
 
-A NATS operator and service account have been configured,
+A NATS operator and system account have been configured,
 and can be accessed using the following commands:
 
 $ vault read nats/operator
-$ vault read nats/accounts/system
-$ vault read nats/accounts/system/user-creds
+$ vault read nats/accounts/SYS
+$ vault read nats/accounts/SYS/user-creds
 
 \033[0;33muse ctrl+D to close this shell and shut down vault
 \033[0m"
@@ -0,0 +1,166 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/jwtutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// IssueActivation issues an activation token that allows the target account
+// to import a private export. The target can be an account in the mount, or
+// the public key of an account outside of it. Targets whose activations have
+// been revoked aren't issued new ones unless the revocation is removed, which
+// re-grants access to the target.
+func (svc *Service) IssueActivation(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	exporter, export, err := privateExport(ctx, req.Storage, fd)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := activationTarget(ctx, req.Storage, fd.Get("target").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := export.Revocations[target]; ok && fd.Get("reinstate").(bool) {
+		delete(export.Revocations, target)
+		if err := putAccount(ctx, req.Storage, exporter); err != nil {
+			return nil, err
+		}
+	}
+
+	if revoked(export, target) {
+		return nil, fmt.Errorf("activation of export %s/%s has been revoked for %s, use reinstate=true to re-grant access", exporter.Name, export.Name, target)
+	}
+
+	ttl := time.Duration(fd.Get("ttl").(int)) * time.Second
+	token, err := issueActivation(exporter, export, target, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"export":     export.Name,
+		"subject":    string(export.Subject),
+		"type":       export.Type.String(),
+		"public_key": target,
+		"token":      token,
+	}
+	if ttl > 0 {
+		data["expires_at"] = time.Now().Add(ttl).Unix()
+	}
+
+	return &logical.Response{Data: data}, nil
+}
+
+// RevokeActivation revokes the activation tokens that were issued to the target
+// account for a private export, and re-issues the exporting account's JWT. If
+// the target is an account in the mount, its imports of the export are removed.
+func (svc *Service) RevokeActivation(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	exporter, export, err := privateExport(ctx, req.Storage, fd)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := activationTarget(ctx, req.Storage, fd.Get("target").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if export.Revocations == nil {
+		export.Revocations = jwt.RevocationList{}
+	}
+	export.Revocations.Revoke(target, time.Now())
+
+	if err := putAccount(ctx, req.Storage, exporter); err != nil {
+		return nil, err
+	}
+
+	importers, err := svc.updateAccounts(ctx, req.Storage, exporter.Name, func(account *Account) bool {
+		if pubKey, err := account.PublicKey(); err != nil || pubKey != target {
+			return false
+		}
+
+		removed := false
+		for name, imp := range account.Imports {
+			if imp.Account == exporter.Name && imp.Export == export.Name {
+				delete(account.Imports, name)
+				removed = true
+			}
+		}
+		return removed
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": target,
+			"jwt":        exporter.Jwt,
+			"importers":  importers,
+		},
+	}, nil
+}
+
+// privateExport reads an export that requires activation tokens to import
+func privateExport(ctx context.Context, s logical.Storage, fd *framework.FieldData) (*Account, *jwt.Export, error) {
+	exporter, err := requireAccount(ctx, s, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := fd.Get("export").(string)
+	export, ok := exporter.Exports[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("export not found: %s/%s", exporter.Name, name)
+	} else if !export.TokenReq {
+		return nil, nil, fmt.Errorf("export does not require activation tokens: %s/%s", exporter.Name, name)
+	}
+
+	return exporter, export, nil
+}
+
+// activationTarget resolves the public key of the account an activation is for
+func activationTarget(ctx context.Context, s logical.Storage, target string) (string, error) {
+	if nkeys.IsValidPublicAccountKey(target) {
+		return target, nil
+	}
+
+	account, err := requireAccount(ctx, s, target)
+	if err != nil {
+		return "", err
+	}
+
+	return account.PublicKey()
+}
+
+// issueActivation issues a token that allows the target account to import one
+// of the exporter's private exports. The token doesn't expire if ttl is 0.
+func issueActivation(exporter *Account, export *jwt.Export, target string, ttl time.Duration) (string, error) {
+	claims := jwt.NewActivationClaims(target)
+	claims.Name = export.Name
+	claims.ImportSubject = export.Subject
+	claims.ImportType = export.Type
+
+	if ttl > 0 {
+		claims.Expires = time.Now().Add(ttl).Unix()
+	}
+
+	if err := jwtutil.Validate(claims); err != nil {
+		return "", err
+	}
+
+	kp, err := nkeys.FromSeed([]byte(exporter.Nkey))
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Encode(kp)
+}
@@ -0,0 +1,273 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// AuthCallout configures the account to delegate authentication and authorization
+// of its clients to a callout service. Auth users that are referred to by name
+// are managed by the mount, which holds their nkeys and issues their credentials.
+type AuthCallout struct {
+	Users           map[string]string `json:"users,omitempty"`
+	ExternalUsers   []string          `json:"external_users,omitempty"`
+	AllowedAccounts []string          `json:"allowed_accounts,omitempty"`
+	XKey            string            `json:"xkey,omitempty"`
+}
+
+// WriteAuthCallout configures the account's auth callout. Auth users can be
+// referred to by name, in which case the mount creates their nkeys, or by
+// public key. Allowed accounts can be accounts in the mount, public keys, or
+// * to allow the callout to place users in any account.
+func (svc *Service) WriteAuthCallout(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	callout := account.AuthCallout
+	if callout == nil {
+		callout = new(AuthCallout)
+	}
+
+	if users, ok := fd.GetOk("auth_users"); ok {
+		managed := make(map[string]string)
+		external := make([]string, 0)
+		for _, user := range users.([]string) {
+			if nkeys.IsValidPublicUserKey(user) {
+				external = append(external, user)
+			} else if seed, ok := callout.Users[user]; ok {
+				managed[user] = seed
+			} else {
+				nk, err := nkeys.CreateUser()
+				if err != nil {
+					return nil, err
+				}
+
+				seed, err := nk.Seed()
+				if err != nil {
+					return nil, err
+				}
+				managed[user] = string(seed)
+			}
+		}
+		if err := revokeAuthUsers(account, callout.Users, managed); err != nil {
+			return nil, err
+		}
+		callout.Users = managed
+		callout.ExternalUsers = external
+	}
+
+	if accounts, ok := fd.GetOk("allowed_accounts"); ok {
+		allowed := make([]string, 0, len(accounts.([]string)))
+		for _, target := range accounts.([]string) {
+			if target == jwt.AnyAccount {
+				allowed = append(allowed, target)
+				continue
+			}
+
+			pubKey, err := activationTarget(ctx, req.Storage, target)
+			if err != nil {
+				return nil, err
+			}
+			allowed = append(allowed, pubKey)
+		}
+		callout.AllowedAccounts = allowed
+	}
+
+	if xkey, ok := fd.GetOk("xkey"); ok {
+		callout.XKey = xkey.(string)
+	}
+
+	if len(callout.Users) == 0 && len(callout.ExternalUsers) == 0 {
+		return nil, errors.New("auth callout requires at least one auth user")
+	}
+
+	account.AuthCallout = callout
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	data, err := authCalloutData(callout)
+	if err != nil {
+		return nil, err
+	}
+	data["jwt"] = account.Jwt
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (svc *Service) ReadAuthCallout(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	} else if account.AuthCallout == nil {
+		return nil, nil
+	}
+
+	data, err := authCalloutData(account.AuthCallout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (svc *Service) DeleteAuthCallout(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	} else if account.AuthCallout == nil {
+		return nil, nil
+	}
+
+	if err := revokeAuthUsers(account, account.AuthCallout.Users, nil); err != nil {
+		return nil, err
+	}
+
+	account.AuthCallout = nil
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// IssueAuthCalloutCreds issues credentials for one of the auth users managed
+// by the mount, which the callout service uses to connect. The credentials are
+// leased like user credentials, and expire after the account's default TTL
+// unless a TTL is given, which is capped by the account's max TTL.
+func (svc *Service) IssueAuthCalloutCreds(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	} else if account.AuthCallout == nil {
+		return nil, fmt.Errorf("auth callout is not configured for account: %s", account.Name)
+	}
+
+	name := fd.Get("user").(string)
+	seed, ok := account.AuthCallout.Users[name]
+	if !ok {
+		return nil, fmt.Errorf("auth user not found: %s", name)
+	}
+
+	nk, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := account.DefaultTtl
+	if t := fd.Get("ttl").(int); t > 0 {
+		ttl = t
+	}
+	if account.MaxTtl > 0 && ttl > account.MaxTtl {
+		ttl = account.MaxTtl
+	}
+
+	claims := new(jwt.UserClaims)
+	claims.Subject = pubKey
+	claims.Name = name
+	claims.Expires = time.Now().Add(time.Duration(ttl) * time.Second).Unix()
+
+	userJwt, err := issueUserJwt(account, "", claims)
+	if err != nil {
+		return nil, err
+	}
+
+	res := svc.Secret.Response(
+		map[string]interface{}{
+			"account_name": account.Name,
+			"public_key":   pubKey,
+			"nkey":         seed,
+			"jwt":          userJwt,
+		},
+		map[string]interface{}{
+			"account_name":       account.Name,
+			"account_public_key": claims.IssuerAccount,
+			"user_name":          name,
+			"signing_key":        "",
+			"user_nkey":          seed,
+			"auth_callout":       true,
+		},
+	)
+	res.Secret.TTL = time.Duration(ttl) * time.Second
+
+	return res, nil
+}
+
+// revokeAuthUsers revokes the managed auth users that are no longer configured.
+// Their leases share the user's nkey, so the user is revoked once it's removed
+// rather than when its leases expire.
+func revokeAuthUsers(account *Account, previous, current map[string]string) error {
+	for name, seed := range previous {
+		if current[name] == seed {
+			continue
+		}
+
+		nk, err := nkeys.FromSeed([]byte(seed))
+		if err != nil {
+			return err
+		}
+
+		pubKey, err := nk.PublicKey()
+		if err != nil {
+			return err
+		}
+
+		if account.Revocations == nil {
+			account.Revocations = jwt.RevocationList{}
+		}
+		account.Revocations.Revoke(pubKey, time.Now())
+	}
+	return nil
+}
+
+// authorization builds the auth callout claims for the account JWT
+func (ac *AuthCallout) authorization() (jwt.ExternalAuthorization, error) {
+	var auth jwt.ExternalAuthorization
+
+	for _, name := range sortedKeys(ac.Users) {
+		nk, err := nkeys.FromSeed([]byte(ac.Users[name]))
+		if err != nil {
+			return auth, err
+		}
+
+		pubKey, err := nk.PublicKey()
+		if err != nil {
+			return auth, err
+		}
+		auth.AuthUsers.Add(pubKey)
+	}
+
+	auth.AuthUsers.Add(ac.ExternalUsers...)
+	auth.AllowedAccounts.Add(ac.AllowedAccounts...)
+	auth.XKey = ac.XKey
+
+	return auth, nil
+}
+
+func authCalloutData(ac *AuthCallout) (map[string]interface{}, error) {
+	auth, err := ac.authorization()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"users":            sortedKeys(ac.Users),
+		"auth_users":       []string(auth.AuthUsers),
+		"allowed_accounts": []string(auth.AllowedAccounts),
+		"xkey":             auth.XKey,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/operator"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+)
+
+// Deleted reports whether the account has been deleted, and only remains as a
+// tombstone until the credentials that were issued for it have expired.
+func (a *Account) Deleted() bool {
+	return a.DeletedAt != 0
+}
+
+// errDeletedAccount is returned when writing to an account that has been deleted
+func errDeletedAccount(name string) error {
+	return fmt.Errorf("account has been deleted, and can't be recreated until it's purged or deleted with force=true: %s", name)
+}
+
+// tombstoneAccount revokes every user of the account, and re-issues its JWT
+// so that servers reject the account's clients. The account is kept until the
+// credentials that were issued for it have expired, so their leases can still
+// be revoked.
+func tombstoneAccount(ctx context.Context, s logical.Storage, account *Account) error {
+	now := time.Now()
+
+	if account.Revocations == nil {
+		account.Revocations = jwt.RevocationList{}
+	}
+	account.Revocations.Revoke(jwt.All, now)
+	account.Revocations.MaybeCompact()
+
+	if account.Limits == nil {
+		account.Limits = defaultLimits()
+	}
+	account.Limits.Conn = 0
+	account.Limits.LeafNodeConn = 0
+
+	pubKey, err := account.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	deletionJwt, err := deletionRecord(ctx, s, account, pubKey)
+	if err != nil {
+		return err
+	}
+
+	account.DeletedAt = now.Unix()
+	account.DeletionJwt = deletionJwt
+
+	return putAccount(ctx, s, account)
+}
+
+// removeDependents removes the imports of other accounts in the mount that
+// refer to a deleted account, and the deleted account from auth callouts that
+// could place users in it. The accounts that were changed are returned.
+func (svc *Service) removeDependents(ctx context.Context, s logical.Storage, deleted *Account) ([]string, error) {
+	pubKey, err := deleted.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.updateAccounts(ctx, s, deleted.Name, func(account *Account) bool {
+		changed := false
+		for name, imp := range account.Imports {
+			if imp.Account == deleted.Name || imp.Claim.Account == pubKey {
+				delete(account.Imports, name)
+				changed = true
+			}
+		}
+
+		if account.AuthCallout != nil {
+			allowed := account.AuthCallout.AllowedAccounts[:0]
+			for _, allowedKey := range account.AuthCallout.AllowedAccounts {
+				if allowedKey != pubKey {
+					allowed = append(allowed, allowedKey)
+				}
+			}
+			changed = changed || len(allowed) != len(account.AuthCallout.AllowedAccounts)
+			account.AuthCallout.AllowedAccounts = allowed
+		}
+
+		return changed
+	})
+}
+
+// deletionRecord issues a request to delete one of the account's public keys
+// from NATS resolvers, which is signed by the account's operator. The key is
+// the account's current key, unless its previous key is being retired.
+func deletionRecord(ctx context.Context, s logical.Storage, account *Account, pubKey string) (string, error) {
+	op, err := operator.GetOperator(ctx, s, account.Operator)
+	if err != nil {
+		return "", err
+	} else if op == nil {
+		return "", fmt.Errorf("operator not found: %s", account.Operator)
+	}
+
+	opPubKey, err := op.PublicKey()
+	if err != nil {
+		return "", err
+	} else if op.SystemAccount == pubKey {
+		return "", errors.New("cannot delete the operator's system account")
+	}
+
+	signer, err := op.AccountSigner(account.OperatorSigningKey)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.NewGenericClaims(opPubKey)
+	claims.Name = account.Name
+	claims.Data["accounts"] = []string{pubKey}
+
+	return claims.Encode(signer)
+}
+
+// PurgeDeletedAccounts removes deleted accounts once the credentials that were
+// issued for them have expired.
+func (svc *Service) PurgeDeletedAccounts(ctx context.Context, req *logical.Request) error {
+	accountNames, err := req.Storage.List(ctx, "accounts/")
+	if err != nil {
+		return err
+	}
+
+	for _, accountName := range accountNames {
+		account, err := getAccount(ctx, req.Storage, accountName)
+		if err != nil {
+			svc.Logger.Warn("failed to read account", "account", accountName, "error", err)
+			continue
+		} else if account == nil || !account.Deleted() {
+			continue
+		}
+
+		maxTtl := time.Duration(account.MaxTtl) * time.Second
+		if maxTtl == 0 {
+			maxTtl = time.Hour
+		}
+
+		if time.Unix(account.DeletedAt, 0).Add(maxTtl).After(time.Now()) {
+			continue
+		}
+
+		if err := req.Storage.Delete(ctx, storagePath(accountName)); err != nil {
+			svc.Logger.Warn("failed to purge deleted account", "account", accountName, "error", err)
+		}
+	}
+
+	return nil
+}
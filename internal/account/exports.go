@@ -0,0 +1,247 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+)
+
+func (svc *Service) ListExports(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(sortedKeys(account.Exports)), nil
+}
+
+// WriteExport creates or updates one of the account's exports. Only the fields
+// provided are changed when updating an existing export.
+func (svc *Service) WriteExport(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fd.Get("export").(string)
+	export, ok := account.Exports[name]
+	if !ok {
+		export = &jwt.Export{Name: name, Type: jwt.Stream}
+	}
+
+	if subject, ok := fd.GetOk("subject"); ok {
+		export.Subject = jwt.Subject(subject.(string))
+	} else if export.Subject == "" {
+		return nil, errors.New("export subject cannot be empty")
+	}
+
+	if exportType, ok := fd.GetOk("type"); ok {
+		switch strings.ToLower(exportType.(string)) {
+		case "stream":
+			export.Type = jwt.Stream
+		case "service":
+			export.Type = jwt.Service
+		default:
+			return nil, fmt.Errorf("invalid export type: %s", exportType)
+		}
+	}
+
+	if tokenReq, ok := fd.GetOk("token_req"); ok {
+		export.TokenReq = tokenReq.(bool)
+	}
+
+	if responseType, ok := fd.GetOk("response_type"); ok {
+		export.ResponseType = jwt.ResponseType(responseType.(string))
+	}
+
+	if threshold, ok := fd.GetOk("response_threshold"); ok {
+		if threshold.(string) == "" {
+			export.ResponseThreshold = 0
+		} else if d, err := time.ParseDuration(threshold.(string)); err != nil {
+			return nil, fmt.Errorf("invalid response threshold: %w", err)
+		} else {
+			export.ResponseThreshold = d
+		}
+	}
+
+	if err := applyLatency(fd, export); err != nil {
+		return nil, err
+	}
+
+	if position, ok := fd.GetOk("account_token_position"); ok {
+		export.AccountTokenPosition = uint(position.(int))
+	}
+
+	if advertise, ok := fd.GetOk("advertise"); ok {
+		export.Advertise = advertise.(bool)
+	}
+
+	if description, ok := fd.GetOk("description"); ok {
+		export.Description = description.(string)
+	}
+
+	if infoUrl, ok := fd.GetOk("info_url"); ok {
+		export.InfoURL = infoUrl.(string)
+	}
+
+	if account.Exports == nil {
+		account.Exports = make(map[string]*jwt.Export)
+	}
+	account.Exports[name] = export
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	importers, err := svc.updateExportImporters(ctx, req.Storage, account, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data := exportData(export)
+	data["jwt"] = account.Jwt
+	data["importers"] = importers
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (svc *Service) ReadExport(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	export, ok := account.Exports[fd.Get("export").(string)]
+	if !ok {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: exportData(export)}, nil
+}
+
+func (svc *Service) DeleteExport(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fd.Get("export").(string)
+	if _, ok := account.Exports[name]; !ok {
+		return nil, nil
+	}
+	delete(account.Exports, name)
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	importers, err := svc.updateExportImporters(ctx, req.Storage, account, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"jwt":       account.Jwt,
+			"importers": importers,
+		},
+	}, nil
+}
+
+// updateExportImporters re-resolves the imports of an export by accounts in the
+// mount after it has changed. Imports that can no longer be resolved, i.e. when
+// the export was deleted or no longer contains the imported subject, are removed.
+func (svc *Service) updateExportImporters(ctx context.Context, s logical.Storage, exporter *Account, export string) ([]string, error) {
+	return svc.updateAccounts(ctx, s, exporter.Name, func(account *Account) bool {
+		changed := false
+		for name, imp := range account.Imports {
+			if imp.Account != exporter.Name || imp.Export != export {
+				continue
+			}
+
+			if err := resolveImport(ctx, s, account, imp); err != nil {
+				svc.Logger.Warn("removing import", "account", account.Name, "import", name, "error", err)
+				delete(account.Imports, name)
+			}
+			changed = true
+		}
+		return changed
+	})
+}
+
+// applyLatency configures service latency tracking. Latency is tracked once a
+// subject to publish results to is set, and disabled if it's set to empty.
+func applyLatency(fd *framework.FieldData, export *jwt.Export) error {
+	if subject, ok := fd.GetOk("latency_subject"); ok {
+		if subject.(string) == "" {
+			export.Latency = nil
+		} else if export.Latency == nil {
+			export.Latency = &jwt.ServiceLatency{Sampling: jwt.Headers}
+		}
+
+		if export.Latency != nil {
+			export.Latency.Results = jwt.Subject(subject.(string))
+		}
+	}
+
+	if sampling, ok := fd.GetOk("latency_sampling"); ok {
+		if export.Latency == nil {
+			return errors.New("latency_subject is required to track service latency")
+		}
+
+		rate, err := parseSamplingRate(sampling.(string))
+		if err != nil {
+			return err
+		}
+		export.Latency.Sampling = rate
+	}
+
+	return nil
+}
+
+func parseSamplingRate(sampling string) (jwt.SamplingRate, error) {
+	if strings.ToLower(sampling) == "headers" {
+		return jwt.Headers, nil
+	}
+
+	rate, err := strconv.Atoi(strings.TrimSuffix(sampling, "%"))
+	if err != nil || rate < 1 || rate > 100 {
+		return 0, fmt.Errorf("latency sampling must be headers or a percentage between 1 and 100: %s", sampling)
+	}
+
+	return jwt.SamplingRate(rate), nil
+}
+
+func exportData(export *jwt.Export) map[string]interface{} {
+	data := map[string]interface{}{
+		"name":                   export.Name,
+		"subject":                string(export.Subject),
+		"type":                   export.Type.String(),
+		"token_req":              export.TokenReq,
+		"response_type":          string(export.ResponseType),
+		"response_threshold":     export.ResponseThreshold.String(),
+		"account_token_position": export.AccountTokenPosition,
+		"advertise":              export.Advertise,
+		"description":            export.Description,
+		"info_url":               export.InfoURL,
+		"revocations":            len(export.Revocations),
+	}
+
+	if export.Latency != nil {
+		data["latency_subject"] = string(export.Latency.Results)
+		if export.Latency.Sampling == jwt.Headers {
+			data["latency_sampling"] = "headers"
+		} else {
+			data["latency_sampling"] = strconv.Itoa(int(export.Latency.Sampling))
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,255 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Import is an import of another account's export. Imports from accounts in
+// the same mount refer to the exporting account and export by name, and are
+// resolved to the exporter's public key when they're written.
+type Import struct {
+	Account string `json:"account,omitempty"`
+	Export  string `json:"export,omitempty"`
+	// Subject narrows the subject imported from an account in the mount. The
+	// export's whole subject is imported if it's empty.
+	Subject jwt.Subject `json:"subject,omitempty"`
+	Claim   jwt.Import  `json:"claim"`
+}
+
+func (svc *Service) ListImports(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(sortedKeys(account.Imports)), nil
+}
+
+// WriteImport creates or updates one of the account's imports. If the import
+// refers to an account in the mount, the export must exist, and an activation
+// token is issued for the import if the export is private.
+func (svc *Service) WriteImport(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fd.Get("import").(string)
+	imp, ok := account.Imports[name]
+	if !ok {
+		imp = &Import{Claim: jwt.Import{Name: name, Type: jwt.Stream}}
+	}
+
+	if exporter, ok := fd.GetOk("account"); ok {
+		if nkeys.IsValidPublicAccountKey(exporter.(string)) {
+			imp.Account = ""
+			imp.Export = ""
+			imp.Subject = ""
+			imp.Claim.Account = exporter.(string)
+		} else {
+			imp.Account = exporter.(string)
+		}
+	}
+
+	if export, ok := fd.GetOk("export"); ok {
+		imp.Export = export.(string)
+	}
+
+	if subject, ok := fd.GetOk("subject"); ok && imp.Account != "" {
+		imp.Subject = jwt.Subject(subject.(string))
+	} else if ok {
+		imp.Claim.Subject = jwt.Subject(subject.(string))
+	}
+
+	if localSubject, ok := fd.GetOk("local_subject"); ok {
+		imp.Claim.LocalSubject = jwt.RenamingSubject(localSubject.(string))
+	}
+
+	if importType, ok := fd.GetOk("type"); ok {
+		switch strings.ToLower(importType.(string)) {
+		case "stream":
+			imp.Claim.Type = jwt.Stream
+		case "service":
+			imp.Claim.Type = jwt.Service
+		default:
+			return nil, fmt.Errorf("invalid import type: %s", importType)
+		}
+	}
+
+	if token, ok := fd.GetOk("token"); ok {
+		imp.Claim.Token = token.(string)
+	}
+
+	if share, ok := fd.GetOk("share"); ok {
+		imp.Claim.Share = share.(bool)
+	}
+
+	if imp.Account != "" {
+		if err := resolveImport(ctx, req.Storage, account, imp); err != nil {
+			return nil, err
+		}
+	} else if imp.Claim.Account == "" {
+		return nil, errors.New("import account cannot be empty")
+	} else if imp.Claim.Subject == "" {
+		return nil, errors.New("import subject cannot be empty")
+	}
+
+	if account.Imports == nil {
+		account.Imports = make(map[string]*Import)
+	}
+	account.Imports[name] = imp
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	data := importData(imp)
+	data["jwt"] = account.Jwt
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (svc *Service) ReadImport(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	imp, ok := account.Imports[fd.Get("import").(string)]
+	if !ok {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: importData(imp)}, nil
+}
+
+func (svc *Service) DeleteImport(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fd.Get("import").(string)
+	if _, ok := account.Imports[name]; !ok {
+		return nil, nil
+	}
+	delete(account.Imports, name)
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// resolveImport fills in an import's claim from the export of an account in
+// the mount that it refers to.
+func resolveImport(ctx context.Context, s logical.Storage, account *Account, imp *Import) error {
+	if imp.Account == account.Name {
+		return errors.New("accounts cannot import their own exports")
+	} else if imp.Export == "" {
+		return errors.New("import export cannot be empty")
+	}
+
+	exporter, err := requireAccount(ctx, s, imp.Account)
+	if err != nil {
+		return err
+	}
+
+	export, ok := exporter.Exports[imp.Export]
+	if !ok {
+		return fmt.Errorf("export not found: %s/%s", imp.Account, imp.Export)
+	}
+
+	exporterKey, err := exporter.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	importerKey, err := account.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	if imp.Subject == "" {
+		imp.Claim.Subject = export.Subject
+	} else if !imp.Subject.IsContainedIn(export.Subject) {
+		return fmt.Errorf("import subject %s is not contained in export subject %s", imp.Subject, export.Subject)
+	} else {
+		imp.Claim.Subject = imp.Subject
+	}
+
+	previousToken := imp.Claim.Token
+	imp.Claim.Account = exporterKey
+	imp.Claim.Type = export.Type
+	imp.Claim.Token = ""
+
+	if export.TokenReq && revoked(export, importerKey) {
+		return fmt.Errorf("activation of export %s/%s has been revoked for account %s, reinstate it to re-grant access", imp.Account, imp.Export, account.Name)
+	} else if export.TokenReq && activates(previousToken, exporterKey, importerKey, export) {
+		imp.Claim.Token = previousToken
+	} else if export.TokenReq {
+		token, err := issueActivation(exporter, export, importerKey, 0)
+		if err != nil {
+			return err
+		}
+		imp.Claim.Token = token
+	}
+
+	return nil
+}
+
+// revoked reports whether activations of the export have been revoked for the
+// importer. Activations issued after a revocation would be accepted by servers,
+// so new ones aren't issued until the revocation is removed (see IssueActivation).
+func revoked(export *jwt.Export, importerKey string) bool {
+	_, ok := export.Revocations[importerKey]
+	_, all := export.Revocations[jwt.All]
+	return ok || all
+}
+
+// activates checks whether a previously issued activation token still grants
+// the importer access to the export, so that it can be kept.
+func activates(token, exporterKey, importerKey string, export *jwt.Export) bool {
+	if token == "" {
+		return false
+	}
+
+	claims, err := jwt.DecodeActivationClaims(token)
+	if err != nil {
+		return false
+	}
+
+	vr := jwt.CreateValidationResults()
+	claims.Validate(vr)
+
+	return vr.IsEmpty() &&
+		claims.Issuer == exporterKey &&
+		claims.Subject == importerKey &&
+		claims.ImportSubject == export.Subject &&
+		claims.ImportType == export.Type &&
+		!export.Revocations.IsRevoked(importerKey, time.Unix(claims.IssuedAt, 0))
+}
+
+func importData(imp *Import) map[string]interface{} {
+	return map[string]interface{}{
+		"name":               imp.Claim.Name,
+		"account":            imp.Account,
+		"export":             imp.Export,
+		"account_public_key": imp.Claim.Account,
+		"subject":            string(imp.Claim.Subject),
+		"local_subject":      string(imp.Claim.LocalSubject),
+		"type":               imp.Claim.Type.String(),
+		"share":              imp.Claim.Share,
+		"token":              imp.Claim.Token,
+	}
+}
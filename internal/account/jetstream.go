@@ -0,0 +1,189 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+)
+
+// WriteJetStream enables JetStream for the account, or updates its limits. If a
+// tier (i.e. R1 or R3) is given, the limits only apply to streams with that
+// number of replicas. Tiered and untiered limits can't be used together.
+func (svc *Service) WriteJetStream(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Limits == nil {
+		account.Limits = defaultLimits()
+	}
+
+	tier, err := jetStreamTier(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	// nats-server ignores untiered limits when tiered limits are present, so
+	// accounts must use one or the other
+	if tiers := jetStreamTiers(account.Limits); tier == "" && len(tiers) > 0 {
+		return nil, fmt.Errorf("account has tiered JetStream limits (%s), which must be deleted before setting untiered limits", strings.Join(tiers, ", "))
+	} else if tier != "" && account.Limits.JetStreamLimits != (jwt.JetStreamLimits{}) {
+		return nil, errors.New("account has untiered JetStream limits, which must be deleted before setting tiered limits")
+	}
+
+	limits, enabled := jetStreamLimits(account.Limits, tier)
+	if !enabled {
+		limits = jwt.JetStreamLimits{
+			MemoryStorage: jwt.NoLimit,
+			DiskStorage:   jwt.NoLimit,
+			Streams:       jwt.NoLimit,
+			Consumer:      jwt.NoLimit,
+		}
+	}
+
+	setLimit := func(field string, limit *int64) {
+		if v, ok := fd.GetOk(field); ok {
+			*limit = v.(int64)
+		}
+	}
+
+	setLimit("memory_storage", &limits.MemoryStorage)
+	setLimit("disk_storage", &limits.DiskStorage)
+	setLimit("streams", &limits.Streams)
+	setLimit("consumers", &limits.Consumer)
+	setLimit("max_ack_pending", &limits.MaxAckPending)
+	setLimit("memory_max_stream_bytes", &limits.MemoryMaxStreamBytes)
+	setLimit("disk_max_stream_bytes", &limits.DiskMaxStreamBytes)
+
+	if v, ok := fd.GetOk("max_bytes_required"); ok {
+		limits.MaxBytesRequired = v.(bool)
+	}
+
+	if limits.MemoryStorage == 0 && limits.DiskStorage == 0 {
+		return nil, errors.New("at least one of memory_storage or disk_storage must be non-zero to enable JetStream")
+	}
+
+	if tier == "" {
+		account.Limits.JetStreamLimits = limits
+	} else {
+		if account.Limits.JetStreamTieredLimits == nil {
+			account.Limits.JetStreamTieredLimits = jwt.JetStreamTieredLimits{}
+		}
+		account.Limits.JetStreamTieredLimits[tier] = limits
+	}
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	data := jetStreamData(limits)
+	data["jwt"] = account.Jwt
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (svc *Service) ReadJetStream(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	} else if account.Limits == nil {
+		return nil, nil
+	}
+
+	tier, err := jetStreamTier(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if limits, enabled := jetStreamLimits(account.Limits, tier); enabled {
+		return &logical.Response{Data: jetStreamData(limits)}, nil
+	} else if tier != "" || len(account.Limits.JetStreamTieredLimits) == 0 {
+		return nil, nil
+	}
+
+	// Without a tier, list the limits of each tier
+	tiers := make(map[string]interface{}, len(account.Limits.JetStreamTieredLimits))
+	for name, limits := range account.Limits.JetStreamTieredLimits {
+		tiers[name] = jetStreamData(limits)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"tiers": tiers,
+		},
+	}, nil
+}
+
+// DeleteJetStream disables JetStream for the account, or removes a single tier
+func (svc *Service) DeleteJetStream(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	} else if account.Limits == nil {
+		return nil, nil
+	}
+
+	tier, err := jetStreamTier(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if tier != "" {
+		delete(account.Limits.JetStreamTieredLimits, tier)
+	} else {
+		account.Limits.JetStreamLimits = jwt.JetStreamLimits{}
+		account.Limits.JetStreamTieredLimits = jwt.JetStreamTieredLimits{}
+	}
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+var tierRegex = regexp.MustCompile(`^R[1-9][0-9]*$`)
+
+// jetStreamTier returns the replica tier a request refers to, which is empty
+// for untiered limits. Tiers are named after their number of replicas.
+func jetStreamTier(fd *framework.FieldData) (string, error) {
+	tier := fd.Get("tier").(string)
+	if tier != "" && !tierRegex.MatchString(tier) {
+		return "", fmt.Errorf("invalid JetStream tier %q, expected R<replicas> (i.e. R1 or R3)", tier)
+	}
+	return tier, nil
+}
+
+func jetStreamTiers(limits *jwt.OperatorLimits) []string {
+	return sortedKeys(limits.JetStreamTieredLimits)
+}
+
+func jetStreamLimits(limits *jwt.OperatorLimits, tier string) (jwt.JetStreamLimits, bool) {
+	if tier == "" {
+		js := limits.JetStreamLimits
+		return js, js != jwt.JetStreamLimits{}
+	}
+
+	js, ok := limits.JetStreamTieredLimits[tier]
+	return js, ok
+}
+
+func jetStreamData(limits jwt.JetStreamLimits) map[string]interface{} {
+	return map[string]interface{}{
+		"memory_storage":          limits.MemoryStorage,
+		"disk_storage":            limits.DiskStorage,
+		"streams":                 limits.Streams,
+		"consumers":               limits.Consumer,
+		"max_ack_pending":         limits.MaxAckPending,
+		"memory_max_stream_bytes": limits.MemoryMaxStreamBytes,
+		"disk_max_stream_bytes":   limits.DiskMaxStreamBytes,
+		"max_bytes_required":      limits.MaxBytesRequired,
+	}
+}
@@ -0,0 +1,77 @@
+package account
+
+import (
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/nats-io/jwt/v2"
+)
+
+// defaultLimits are the limits of an account that hasn't configured any,
+// which matches the unlimited defaults used by nsc.
+func defaultLimits() *jwt.OperatorLimits {
+	return &jwt.OperatorLimits{
+		NatsLimits: jwt.NatsLimits{
+			Subs:    jwt.NoLimit,
+			Data:    jwt.NoLimit,
+			Payload: jwt.NoLimit,
+		},
+		AccountLimits: jwt.AccountLimits{
+			Imports:         jwt.NoLimit,
+			Exports:         jwt.NoLimit,
+			WildcardExports: true,
+			Conn:            jwt.NoLimit,
+			LeafNodeConn:    jwt.NoLimit,
+		},
+		JetStreamTieredLimits: jwt.JetStreamTieredLimits{},
+	}
+}
+
+// applyLimits updates the account's limits with any that were provided
+func applyLimits(fd *framework.FieldData, account *Account) {
+	limits := account.Limits
+	if limits == nil {
+		limits = defaultLimits()
+	}
+
+	changed := false
+	setLimit := func(field string, limit *int64) {
+		if v, ok := fd.GetOk(field); ok {
+			*limit = v.(int64)
+			changed = true
+		}
+	}
+
+	setLimit("max_connections", &limits.Conn)
+	setLimit("max_leafnodes", &limits.LeafNodeConn)
+	setLimit("max_subscriptions", &limits.Subs)
+	setLimit("max_payload", &limits.Payload)
+	setLimit("max_data", &limits.Data)
+	setLimit("max_imports", &limits.Imports)
+	setLimit("max_exports", &limits.Exports)
+
+	if v, ok := fd.GetOk("wildcard_exports"); ok {
+		limits.WildcardExports = v.(bool)
+		changed = true
+	}
+
+	if changed {
+		account.Limits = limits
+	}
+}
+
+func limitsData(limits *jwt.OperatorLimits) map[string]interface{} {
+	if limits == nil {
+		limits = defaultLimits()
+	}
+
+	return map[string]interface{}{
+		"max_connections":   limits.Conn,
+		"max_leafnodes":     limits.LeafNodeConn,
+		"max_subscriptions": limits.Subs,
+		"max_payload":       limits.Payload,
+		"max_data":          limits.Data,
+		"max_imports":       limits.Imports,
+		"max_exports":       limits.Exports,
+		"wildcard_exports":  limits.WildcardExports,
+		"jetstream_enabled": limits.IsJSEnabled(),
+	}
+}
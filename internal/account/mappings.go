@@ -0,0 +1,164 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+)
+
+func (svc *Service) ListMappings(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(sortedKeys(account.Mappings)), nil
+}
+
+// WriteMapping maps a subject to one or more weighted destinations. Messages
+// published to the subject are sent to one of the destinations, chosen based
+// on their weights. Destinations can be scoped to a cluster.
+func (svc *Service) WriteMapping(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := jwt.Subject(fd.Get("subject").(string))
+	if subject == "" {
+		return nil, errors.New("mapping subject cannot be empty")
+	}
+
+	destinations := fd.Get("destinations").([]string)
+	if len(destinations) == 0 {
+		return nil, errors.New("mapping requires at least one destination")
+	}
+
+	mappings := make([]jwt.WeightedMapping, 0, len(destinations))
+	for _, destination := range destinations {
+		mapping, err := parseDestination(destination)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	if err := validateWeights(subject, mappings); err != nil {
+		return nil, err
+	}
+
+	if account.Mappings == nil {
+		account.Mappings = jwt.Mapping{}
+	}
+	account.Mappings[subject] = mappings
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	data := mappingData(subject, mappings)
+	data["jwt"] = account.Jwt
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (svc *Service) ReadMapping(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := jwt.Subject(fd.Get("subject").(string))
+	mappings, ok := account.Mappings[subject]
+	if !ok {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: mappingData(subject, mappings)}, nil
+}
+
+func (svc *Service) DeleteMapping(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := jwt.Subject(fd.Get("subject").(string))
+	if _, ok := account.Mappings[subject]; !ok {
+		return nil, nil
+	}
+	delete(account.Mappings, subject)
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// parseDestination parses a mapping destination in the format
+// <subject>[:<weight>[:<cluster>]], where the weight is a percentage.
+func parseDestination(destination string) (jwt.WeightedMapping, error) {
+	parts := strings.Split(destination, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return jwt.WeightedMapping{}, fmt.Errorf("invalid mapping destination: %s", destination)
+	}
+
+	mapping := jwt.WeightedMapping{Subject: jwt.Subject(parts[0])}
+
+	if len(parts) > 1 && parts[1] != "" {
+		weight, err := strconv.Atoi(parts[1])
+		if err != nil || weight < 1 || weight > 100 {
+			return jwt.WeightedMapping{}, fmt.Errorf("mapping destination weight must be between 1 and 100: %s", destination)
+		}
+		mapping.Weight = uint8(weight)
+	}
+
+	if len(parts) > 2 {
+		mapping.Cluster = parts[2]
+	}
+
+	return mapping, nil
+}
+
+// validateWeights checks that the weights of the destinations in each cluster
+// don't add up to more than 100%
+func validateWeights(subject jwt.Subject, mappings []jwt.WeightedMapping) error {
+	totals := make(map[string]int)
+	for _, mapping := range mappings {
+		totals[mapping.Cluster] += int(mapping.GetWeight())
+	}
+
+	for cluster, total := range totals {
+		if total <= 100 {
+			continue
+		} else if cluster == "" {
+			return fmt.Errorf("mapping %s destination weights exceed 100%%", subject)
+		}
+		return fmt.Errorf("mapping %s destination weights exceed 100%% in cluster %s", subject, cluster)
+	}
+
+	return nil
+}
+
+func mappingData(subject jwt.Subject, mappings []jwt.WeightedMapping) map[string]interface{} {
+	destinations := make([]map[string]interface{}, 0, len(mappings))
+	for _, mapping := range mappings {
+		destinations = append(destinations, map[string]interface{}{
+			"subject": string(mapping.Subject),
+			"weight":  mapping.GetWeight(),
+			"cluster": mapping.Cluster,
+		})
+	}
+
+	return map[string]interface{}{
+		"subject":      string(subject),
+		"destinations": destinations,
+	}
+}
@@ -3,10 +3,11 @@ package account
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/egoodhall/vault-secrets-engine-nats/internal/nkutil"
-	"github.com/egoodhall/vault-secrets-engine-nats/internal/operator"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -18,6 +19,12 @@ type Paths []*framework.Path
 
 func NewPaths(svc *Service) Paths {
 	return []*framework.Path{
+		{
+			Pattern: "accounts/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{Callback: svc.List},
+			},
+		},
 		{
 			Pattern: "accounts/" + framework.GenericNameRegex("name"),
 			Fields: map[string]*framework.FieldSchema{
@@ -28,7 +35,7 @@ func NewPaths(svc *Service) Paths {
 				},
 				"nkey": {
 					Type:        framework.TypeString,
-					Description: "The NKey that will be used as the root of the trust chain",
+					Description: "The NKey that will be used as the root of the trust chain. It can only be set when creating the account",
 					Required:    false,
 				},
 				"default_ttl": {
@@ -43,14 +50,533 @@ func NewPaths(svc *Service) Paths {
 					Default:     "1h",
 					Required:    false,
 				},
+				"jwt_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long the account JWT is valid for. It is re-signed before it expires. The JWT doesn't expire if not set",
+					Required:    false,
+				},
+				"description": {
+					Type:        framework.TypeString,
+					Description: "A description of the account to include in the account JWT",
+					Required:    false,
+				},
+				"info_url": {
+					Type:        framework.TypeString,
+					Description: "A URL with more information about the account to include in the account JWT",
+					Required:    false,
+				},
+				"tags": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Tags to include in the account JWT",
+					Required:    false,
+				},
+				"force": {
+					Type:        framework.TypeBool,
+					Description: "Delete the account immediately, instead of keeping it until its credentials have expired",
+					Required:    false,
+				},
+				"operator": {
+					Type:        framework.TypeString,
+					Description: "The name of the operator that issues the account. The default operator is used if not set",
+					Required:    false,
+				},
+				"user_signing_key": {
+					Type:        framework.TypeString,
+					Description: "The name of the account signing key that issues user credentials",
+					Required:    false,
+				},
+				"operator_signing_key": {
+					Type:        framework.TypeString,
+					Description: "The name of the operator signing key used to issue the account JWT. The operator's identity key is used if not set",
+					Required:    false,
+				},
+				"default_permissions": {
+					Type:        framework.TypeMap,
+					Description: "The permissions of users without permissions of their own: pub_allow, pub_deny, sub_allow, sub_deny and allow_responses. Replaces any previous default permissions, which are removed if empty",
+					Required:    false,
+				},
+				"max_connections": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of active client connections. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"max_leafnodes": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of active leaf node connections. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"max_subscriptions": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of subscriptions. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"max_payload": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum message payload, in bytes. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"max_data": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of bytes. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"max_imports": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of imports. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"max_exports": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of exports. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"wildcard_exports": {
+					Type:        framework.TypeBool,
+					Description: "Whether exports may contain wildcards. Allowed if not set",
+					Required:    false,
+				},
 			},
+			ExistenceCheck: svc.ExistenceCheck,
 			Operations: map[logical.Operation]framework.OperationHandler{
-				logical.CreateOperation: &framework.PathOperation{Callback: svc.Write},
-				logical.UpdateOperation: &framework.PathOperation{Callback: svc.Write},
+				logical.CreateOperation: &framework.PathOperation{Callback: svc.Create},
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.Update},
+				logical.PatchOperation:  &framework.PathOperation{Callback: svc.Update},
 				logical.ReadOperation:   &framework.PathOperation{Callback: svc.Read},
 				logical.DeleteOperation: &framework.PathOperation{Callback: svc.Delete},
 			},
 		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/rotate",
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"nkey": {
+					Type:        framework.TypeString,
+					Description: "The account NKey seed to replace the account's identity key with. One is generated if not provided",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.Rotate},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/jetstream" + framework.OptionalParamRegex("tier"),
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"tier": {
+					Type:        framework.TypeString,
+					Description: "The replica tier (i.e. R1 or R3) the limits apply to. The limits apply to all streams if not set",
+					Required:    false,
+				},
+				"memory_storage": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum bytes of memory storage across all streams. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"disk_storage": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum bytes of disk storage across all streams. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"streams": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of streams. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"consumers": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of consumers. Unlimited (-1) if not set",
+					Required:    false,
+				},
+				"max_ack_pending": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum number of pending acks of a consumer. Unlimited if not set",
+					Required:    false,
+				},
+				"memory_max_stream_bytes": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum bytes a memory backed stream can have. Unlimited if not set",
+					Required:    false,
+				},
+				"disk_max_stream_bytes": {
+					Type:        framework.TypeInt64,
+					Description: "The maximum bytes a disk backed stream can have. Unlimited if not set",
+					Required:    false,
+				},
+				"max_bytes_required": {
+					Type:        framework.TypeBool,
+					Description: "Whether streams must set a max bytes limit",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{Callback: svc.WriteJetStream},
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.WriteJetStream},
+				logical.ReadOperation:   &framework.PathOperation{Callback: svc.ReadJetStream},
+				logical.DeleteOperation: &framework.PathOperation{Callback: svc.DeleteJetStream},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/exports/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{Callback: svc.ListExports},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/exports/" + framework.GenericNameRegex("export"),
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"export": {
+					Type:        framework.TypeString,
+					Description: "The export name",
+					Required:    true,
+				},
+				"subject": {
+					Type:        framework.TypeString,
+					Description: "The subject being exported",
+					Required:    false,
+				},
+				"type": {
+					Type:        framework.TypeString,
+					Description: "The export type: stream or service. Defaults to stream",
+					Required:    false,
+				},
+				"token_req": {
+					Type:        framework.TypeBool,
+					Description: "Whether importing accounts require an activation token (a private export)",
+					Required:    false,
+				},
+				"response_type": {
+					Type:        framework.TypeString,
+					Description: "The response type of a service: Singleton, Stream or Chunked",
+					Required:    false,
+				},
+				"response_threshold": {
+					Type:        framework.TypeString,
+					Description: "How long a service waits for responses (i.e. 500ms)",
+					Required:    false,
+				},
+				"latency_subject": {
+					Type:        framework.TypeString,
+					Description: "The subject service latency measurements are published to. Latency isn't tracked if not set",
+					Required:    false,
+				},
+				"latency_sampling": {
+					Type:        framework.TypeString,
+					Description: "The percentage (1-100) of requests that latency is measured for, or headers to sample based on request headers",
+					Required:    false,
+				},
+				"account_token_position": {
+					Type:        framework.TypeInt,
+					Description: "The position of a wildcard token in the subject that must match the importing account's public key",
+					Required:    false,
+				},
+				"advertise": {
+					Type:        framework.TypeBool,
+					Description: "Whether the export is advertised to other accounts",
+					Required:    false,
+				},
+				"description": {
+					Type:        framework.TypeString,
+					Description: "A description of the export",
+					Required:    false,
+				},
+				"info_url": {
+					Type:        framework.TypeString,
+					Description: "A URL with more information about the export",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{Callback: svc.WriteExport},
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.WriteExport},
+				logical.ReadOperation:   &framework.PathOperation{Callback: svc.ReadExport},
+				logical.DeleteOperation: &framework.PathOperation{Callback: svc.DeleteExport},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/exports/" + framework.GenericNameRegex("export") + "/activations/" + framework.GenericNameRegex("target"),
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The exporting account name",
+					Required:    true,
+				},
+				"export": {
+					Type:        framework.TypeString,
+					Description: "The export name",
+					Required:    true,
+				},
+				"target": {
+					Type:        framework.TypeString,
+					Description: "The name of the importing account in this mount, or the public key of an account outside of it",
+					Required:    true,
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long the activation token is valid for. Tokens don't expire if not set",
+					Required:    false,
+				},
+				"reinstate": {
+					Type:        framework.TypeBool,
+					Description: "Remove the revocation of the target's activations, so that it can be issued activations again. Tokens issued before the revocation are trusted again",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{Callback: svc.IssueActivation},
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.IssueActivation},
+				logical.DeleteOperation: &framework.PathOperation{Callback: svc.RevokeActivation},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/imports/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{Callback: svc.ListImports},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/imports/" + framework.GenericNameRegex("import"),
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"import": {
+					Type:        framework.TypeString,
+					Description: "The import name",
+					Required:    true,
+				},
+				"account": {
+					Type:        framework.TypeString,
+					Description: "The name of the exporting account in this mount, or the public key of an account outside of it",
+					Required:    false,
+				},
+				"export": {
+					Type:        framework.TypeString,
+					Description: "The name of the export to import, if the exporting account is in this mount",
+					Required:    false,
+				},
+				"subject": {
+					Type:        framework.TypeString,
+					Description: "The subject being imported. Defaults to the export's subject for accounts in this mount",
+					Required:    false,
+				},
+				"local_subject": {
+					Type:        framework.TypeString,
+					Description: "The subject the import is available on in this account",
+					Required:    false,
+				},
+				"type": {
+					Type:        framework.TypeString,
+					Description: "The import type (stream or service) of an import from an account outside of this mount",
+					Required:    false,
+				},
+				"token": {
+					Type:        framework.TypeString,
+					Description: "The activation token for a private export of an account outside of this mount",
+					Required:    false,
+				},
+				"share": {
+					Type:        framework.TypeBool,
+					Description: "Whether to share information with the exporter for service latency tracking",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{Callback: svc.WriteImport},
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.WriteImport},
+				logical.ReadOperation:   &framework.PathOperation{Callback: svc.ReadImport},
+				logical.DeleteOperation: &framework.PathOperation{Callback: svc.DeleteImport},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/signing-keys/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{Callback: svc.ListSigningKeys},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/signing-keys/" + framework.GenericNameRegex("key"),
+			Fields: withPermissionFields(map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "The signing key name",
+					Required:    true,
+				},
+				"nkey": {
+					Type:        framework.TypeString,
+					Description: "The account NKey seed to use as the signing key. One is generated if not provided",
+					Required:    false,
+				},
+				"scoped": {
+					Type:        framework.TypeBool,
+					Description: "Whether users issued by the signing key get their permissions from the signing key's scope. Setting any permission field or the role makes the key scoped",
+					Required:    false,
+				},
+				"role": {
+					Type:        framework.TypeString,
+					Description: "The role name of a scoped signing key",
+					Required:    false,
+				},
+			}),
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{Callback: svc.WriteSigningKey},
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.WriteSigningKey},
+				logical.ReadOperation:   &framework.PathOperation{Callback: svc.ReadSigningKey},
+				logical.DeleteOperation: &framework.PathOperation{Callback: svc.DeleteSigningKey},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/signing-keys/" + framework.GenericNameRegex("key") + "/rotate",
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "The signing key name",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.RotateSigningKey},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/mappings/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{Callback: svc.ListMappings},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/mappings/" + framework.MatchAllRegex("subject"),
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"subject": {
+					Type:        framework.TypeString,
+					Description: "The subject being mapped",
+					Required:    true,
+				},
+				"destinations": {
+					Type:        framework.TypeStringSlice,
+					Description: "The subjects messages are mapped to, in the format <subject>[:<weight>[:<cluster>]]. Weights are percentages, and default to 100. Destinations aren't split on commas, which mapping functions use",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{Callback: svc.WriteMapping},
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.WriteMapping},
+				logical.ReadOperation:   &framework.PathOperation{Callback: svc.ReadMapping},
+				logical.DeleteOperation: &framework.PathOperation{Callback: svc.DeleteMapping},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/auth-callout",
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"auth_users": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "The users the callout service connects as, which bypass the callout. Users referred to by name are managed by the mount, others by public key",
+					Required:    false,
+				},
+				"allowed_accounts": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "The accounts (by name in this mount, or public key) that the callout service can place users in, or * for any account",
+					Required:    false,
+				},
+				"xkey": {
+					Type:        framework.TypeString,
+					Description: "The public curve key that callout requests are encrypted with",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{Callback: svc.WriteAuthCallout},
+				logical.UpdateOperation: &framework.PathOperation{Callback: svc.WriteAuthCallout},
+				logical.ReadOperation:   &framework.PathOperation{Callback: svc.ReadAuthCallout},
+				logical.DeleteOperation: &framework.PathOperation{Callback: svc.DeleteAuthCallout},
+			},
+		},
+		{
+			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/auth-callout/creds/" + framework.GenericNameRegex("user"),
+			Fields: map[string]*framework.FieldSchema{
+				"account_name": {
+					Type:        framework.TypeString,
+					Description: "The account name",
+					Required:    true,
+				},
+				"user": {
+					Type:        framework.TypeString,
+					Description: "The name of an auth user managed by the mount",
+					Required:    true,
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long the credentials are valid for. Defaults to the account's default TTL, and is capped by its max TTL",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{Callback: svc.IssueAuthCalloutCreds},
+			},
+		},
 		{
 			Pattern: "accounts/" + framework.GenericNameRegex("account_name") + "/user-creds",
 			Fields: map[string]*framework.FieldSchema{
@@ -72,6 +598,12 @@ func NewPaths(svc *Service) Paths {
 					Default:     "",
 					Required:    false,
 				},
+				"signing_key": {
+					Type:        framework.TypeString,
+					Description: "The name of the scoped account signing key (role) to issue the user credentials with. Defaults to the account's user signing key",
+					Default:     "",
+					Required:    false,
+				},
 				"ttl": {
 					Type:        framework.TypeDurationSecond,
 					Description: "The TTL of the generated user credentials",
@@ -91,76 +623,148 @@ type Service struct {
 	Logger hclog.Logger
 }
 
-func (svc *Service) Write(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+// ExistenceCheck lets Vault distinguish between creating and updating accounts.
+// Deleted accounts exist until they're purged, so they can't be recreated while
+// leases of their credentials remain.
+func (svc *Service) ExistenceCheck(ctx context.Context, req *logical.Request, fd *framework.FieldData) (bool, error) {
+	account, err := getAccount(ctx, req.Storage, fd.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+
+	return account != nil, nil
+}
+
+// Create creates an account, generating its identity key if one isn't given
+func (svc *Service) Create(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
 	name := fd.Get("name").(string)
 	if name == "" {
 		return nil, errors.New("account cannot be empty name")
 	}
 
+	if existing, err := getAccount(ctx, req.Storage, name); err != nil {
+		return nil, err
+	} else if existing != nil && existing.Deleted() {
+		return nil, errDeletedAccount(name)
+	} else if existing != nil {
+		return nil, fmt.Errorf("account already exists: %s", name)
+	}
+
 	accountNkey, err := nkutil.GetOrDefault(fd, "nkey", nkeys.CreateAccount)
 	if err != nil {
 		return nil, err
 	}
 
+	accountSeed, err := accountNkey.Seed()
+	if err != nil {
+		return nil, err
+	}
+
+	account := &Account{
+		Name:       name,
+		Nkey:       string(accountSeed),
+		DefaultTtl: fd.Get("default_ttl").(int),
+		MaxTtl:     fd.Get("max_ttl").(int),
+	}
+
+	return writeAccount(ctx, req, fd, account)
+}
+
+// Update changes only the fields of an account that are provided. The account's
+// identity key can't be changed here, as that would invalidate all of its users,
+// so it has to be replaced by rotating the account.
+func (svc *Service) Update(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	name := fd.Get("name").(string)
 	account, err := getAccount(ctx, req.Storage, name)
 	if err != nil {
 		return nil, err
 	} else if account == nil {
-		account = new(Account)
+		return nil, fmt.Errorf("account not found: %s", name)
+	} else if account.Deleted() {
+		return nil, errDeletedAccount(name)
 	}
 
-	if account.DefaultTtl == 0 {
-		account.DefaultTtl = fd.Get("default_ttl").(int)
+	if _, ok := fd.GetOk("nkey"); ok {
+		nk, err := nkutil.GetOrDefault(fd, "nkey", nkeys.CreateAccount)
+		if err != nil {
+			return nil, err
+		}
+
+		seed, err := nk.Seed()
+		if err != nil {
+			return nil, err
+		} else if string(seed) != account.Nkey {
+			return nil, fmt.Errorf("account nkey can only be replaced by rotating the account (accounts/%s/rotate)", account.Name)
+		}
 	}
 
-	if account.MaxTtl == 0 {
-		account.MaxTtl = fd.Get("max_ttl").(int)
+	if ttl, ok := fd.GetOk("default_ttl"); ok {
+		account.DefaultTtl = ttl.(int)
 	}
 
-	if accountSeed, err := accountNkey.Seed(); err != nil {
-		return nil, err
-	} else {
-		account.Nkey = string(accountSeed)
+	if ttl, ok := fd.GetOk("max_ttl"); ok {
+		account.MaxTtl = ttl.(int)
 	}
 
-	if entry, err := logical.StorageEntryJSON(storagePath(name), account); err != nil {
-		return nil, err
-	} else if err := req.Storage.Put(ctx, entry); err != nil {
-		return nil, err
+	return writeAccount(ctx, req, fd, account)
+}
+
+// writeAccount applies the optional account fields that were provided, and
+// persists the account
+func writeAccount(ctx context.Context, req *logical.Request, fd *framework.FieldData, account *Account) (*logical.Response, error) {
+	if account.MaxTtl != 0 && account.DefaultTtl > account.MaxTtl {
+		return nil, errors.New("default_ttl cannot be greater than max_ttl")
 	}
 
-	pubKey, err := accountNkey.PublicKey()
-	if err != nil {
-		return nil, err
+	if ttl, ok := fd.GetOk("jwt_ttl"); ok {
+		account.JwtTtl = ttl.(int)
 	}
 
-	claims := new(jwt.AccountClaims)
-	claims.Subject = pubKey
-	claims.Name = name
-	claims.Revocations = account.Revocations
+	if description, ok := fd.GetOk("description"); ok {
+		account.Description = description.(string)
+	}
 
-	operator, err := operator.GetOperator(ctx, req.Storage)
-	if err != nil {
+	if infoUrl, ok := fd.GetOk("info_url"); ok {
+		account.InfoUrl = infoUrl.(string)
+	}
+
+	if tags, ok := fd.GetOk("tags"); ok {
+		account.Tags = tags.([]string)
+	}
+
+	if opName, ok := fd.GetOk("operator"); ok {
+		account.Operator = opName.(string)
+	}
+
+	if signingKey, ok := fd.GetOk("operator_signing_key"); ok {
+		account.OperatorSigningKey = signingKey.(string)
+	}
+
+	if signingKey, ok := fd.GetOk("user_signing_key"); ok {
+		if _, exists := account.SigningKeys[signingKey.(string)]; !exists {
+			return nil, fmt.Errorf("account signing key not found: %s", signingKey)
+		} else if _, retired := account.SigningKeyExpirations[signingKey.(string)]; retired {
+			return nil, fmt.Errorf("account signing key has been retired: %s", signingKey)
+		}
+		account.UserSigningKey = signingKey.(string)
+	}
+
+	applyLimits(fd, account)
+	if err := applyDefaultPermissions(fd, account); err != nil {
 		return nil, err
 	}
 
-	operatorNkey, err := nkeys.FromSeed([]byte(operator.Nkey))
-	if err != nil {
+	if err := putAccount(ctx, req.Storage, account); err != nil {
 		return nil, err
 	}
 
-	accountJwt, err := claims.Encode(operatorNkey)
+	data, err := accountData(account)
 	if err != nil {
 		return nil, err
 	}
+	data["name"] = account.Name
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"name":       name,
-			"public_key": pubKey,
-			"jwt":        accountJwt,
-		},
-	}, nil
+	return &logical.Response{Data: data}, nil
 }
 
 func (svc *Service) Delete(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
@@ -169,61 +773,144 @@ func (svc *Service) Delete(ctx context.Context, req *logical.Request, fd *framew
 		return nil, errors.New("account cannot be empty name")
 	}
 
-	if err := req.Storage.Delete(ctx, storagePath(name)); err != nil {
+	account, err := getAccount(ctx, req.Storage, name)
+	if err != nil {
 		return nil, err
+	} else if account == nil {
+		return nil, nil
 	}
 
-	return nil, nil
-}
+	// Forced deletions drop the account immediately. Leases of its credentials
+	// can still be revoked, but the account's clients are trusted by servers
+	// until they receive the deletion record.
+	if fd.Get("force").(bool) {
+		pubKey, err := account.PublicKey()
+		if err != nil {
+			return nil, err
+		}
 
-func (svc *Service) Read(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
-	name := fd.Get("name").(string)
-	if name == "" {
-		return nil, errors.New("account cannot be empty name")
+		deletionJwt, err := deletionRecord(ctx, req.Storage, account, pubKey)
+		if err != nil && !account.Deleted() {
+			return nil, err
+		} else if err != nil {
+			deletionJwt = account.DeletionJwt
+		}
+
+		if err := req.Storage.Delete(ctx, storagePath(name)); err != nil {
+			return nil, err
+		}
+
+		dependents, err := svc.removeDependents(ctx, req.Storage, account)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"deletion_jwt": deletionJwt,
+				"dependents":   dependents,
+			},
+		}, nil
 	}
 
-	account, err := getAccount(ctx, req.Storage, name)
-	if err != nil {
-		return nil, err
+	if !account.Deleted() {
+		if err := tombstoneAccount(ctx, req.Storage, account); err != nil {
+			return nil, err
+		}
 	}
 
-	accountNkey, err := nkeys.FromSeed([]byte(account.Nkey))
+	dependents, err := svc.removeDependents(ctx, req.Storage, account)
 	if err != nil {
 		return nil, err
 	}
 
-	pubKey, err := accountNkey.PublicKey()
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"jwt":          account.Jwt,
+			"deleted_at":   account.DeletedAt,
+			"deletion_jwt": account.DeletionJwt,
+			"dependents":   dependents,
+		},
+	}, nil
+}
+
+// List lists the mount's accounts, along with a summary of each account that
+// is shown by detailed listings (vault list -detailed).
+func (svc *Service) List(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, "accounts/")
 	if err != nil {
 		return nil, err
 	}
 
-	claims := new(jwt.AccountClaims)
-	claims.Subject = pubKey
-	claims.Name = name
-	claims.Revocations = account.Revocations
+	names := make([]string, 0, len(entries))
+	keyInfo := make(map[string]interface{}, len(entries))
+	for _, name := range entries {
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
 
-	operator, err := operator.GetOperator(ctx, req.Storage)
-	if err != nil {
-		return nil, err
+		account, err := getAccount(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		} else if account == nil || account.Deleted() {
+			continue
+		}
+
+		pubKey, err := account.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+		keyInfo[name] = map[string]interface{}{
+			"public_key":   pubKey,
+			"operator":     account.Operator,
+			"default_ttl":  account.DefaultTtl,
+			"max_ttl":      account.MaxTtl,
+			"jwt_ttl":      account.JwtTtl,
+			"revocations":  len(account.Revocations),
+			"signing_keys": len(account.SigningKeys),
+			"jwt_size":     len(account.Jwt),
+		}
 	}
 
-	operatorNkey, err := nkeys.FromSeed([]byte(operator.Nkey))
+	return logical.ListResponseWithInfo(names, keyInfo), nil
+}
+
+func (svc *Service) Read(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	name := fd.Get("name").(string)
+	if name == "" {
+		return nil, errors.New("account cannot be empty name")
+	}
+
+	account, err := getAccount(ctx, req.Storage, name)
 	if err != nil {
 		return nil, err
+	} else if account == nil {
+		return nil, nil
 	}
 
-	accountJwt, err := claims.Encode(operatorNkey)
+	// The stored JWT is kept if it's current. Otherwise, i.e. it was issued by a
+	// key the operator no longer signs accounts with, a new one is returned. It
+	// isn't persisted until the account is next written.
+	if !account.Deleted() {
+		if err := issueJwt(ctx, req.Storage, account); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := accountData(account)
 	if err != nil {
 		return nil, err
 	}
+	data["account_name"] = name
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"account_name": name,
-			"public_key":   pubKey,
-			"jwt":          accountJwt,
-		},
-	}, nil
+	if account.Deleted() {
+		data["deleted_at"] = account.DeletedAt
+		data["deletion_jwt"] = account.DeletionJwt
+	}
+
+	return &logical.Response{Data: data}, nil
 }
 
 func (svc *Service) LeaseUserCreds(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
@@ -232,7 +919,12 @@ func (svc *Service) LeaseUserCreds(ctx context.Context, req *logical.Request, fd
 		return nil, errors.New("account name cannot be empty")
 	}
 
-	account, err := getAccount(ctx, req.Storage, accountName)
+	account, err := requireAccount(ctx, req.Storage, accountName)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, err := migrateUserSigningKey(ctx, req.Storage, account)
 	if err != nil {
 		return nil, err
 	}
@@ -260,12 +952,14 @@ func (svc *Service) LeaseUserCreds(ctx context.Context, req *logical.Request, fd
 		claims.Name = name
 	}
 
-	accountNkey, err := nkeys.FromSeed([]byte(account.Nkey))
-	if err != nil {
-		return nil, err
+	signingKey := fd.Get("signing_key").(string)
+	if signingKey != "" {
+		if err := roleSigningKey(account, signingKey); err != nil {
+			return nil, err
+		}
 	}
 
-	userJwt, err := claims.Encode(accountNkey)
+	userJwt, err := issueUserJwt(account, signingKey, claims)
 	if err != nil {
 		return nil, err
 	}
@@ -275,11 +969,6 @@ func (svc *Service) LeaseUserCreds(ctx context.Context, req *logical.Request, fd
 		return nil, err
 	}
 
-	accountSeed, err := accountNkey.Seed()
-	if err != nil {
-		return nil, err
-	}
-
 	res := svc.Secret.Response(
 		map[string]interface{}{
 			"account_name": accountName,
@@ -287,33 +976,74 @@ func (svc *Service) LeaseUserCreds(ctx context.Context, req *logical.Request, fd
 			"jwt":          userJwt,
 		},
 		map[string]interface{}{
-			"account_name": accountName,
-			"account_nkey": string(accountSeed),
-			"user_name":    claims.Name,
-			"user_nkey":    string(userSeed),
+			"account_name":       accountName,
+			"account_public_key": claims.IssuerAccount,
+			"user_name":          claims.Name,
+			"signing_key":        signingKey,
+			"user_nkey":          string(userSeed),
 		},
 	)
+	if migrated {
+		res.AddWarning(migratedWarning(account))
+	}
 
 	return res, nil
 }
 
+// migrateUserSigningKey adds the default signing key to accounts created before
+// signing keys were supported, so they can issue users. The account's JWT is
+// re-signed, and it reports whether the account was migrated.
+func migrateUserSigningKey(ctx context.Context, s logical.Storage, account *Account) (bool, error) {
+	if account.UserSigningKey != "" {
+		return false, nil
+	}
+
+	if err := putAccount(ctx, s, account); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// migratedWarning warns that the credentials won't be accepted until the JWT of
+// a migrated account has been distributed to the servers
+func migratedWarning(account *Account) string {
+	return fmt.Sprintf("account %s was given the %q signing key to issue users, and its re-signed JWT must be distributed before these credentials are accepted", account.Name, account.UserSigningKey)
+}
+
 type UserCredsService struct {
 	Logger hclog.Logger
 }
 
 // Generates a new JWT with the
 func (ucSvc *UserCredsService) RenewUserCreds(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
-	account, err := getAccount(ctx, req.Storage, req.Secret.InternalData["account_name"].(string))
+	// Leases issued before account signing keys were supported also hold the
+	// account's seed, which is ignored in favor of the account's signing key
+	account, err := requireAccount(ctx, req.Storage, req.Secret.InternalData["account_name"].(string))
 	if err != nil {
 		return nil, err
 	}
 
-	accountNkey, err := nkeys.FromSeed([]byte(req.Secret.InternalData["account_nkey"].(string)))
+	if ok, err := issuedFor(account, req.Secret); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("account has been replaced since the credentials were issued: %s", account.Name)
+	}
+
+	migrated, err := migrateUserSigningKey(ctx, req.Storage, account)
 	if err != nil {
 		return nil, err
 	}
 
-	userNkey, err := nkeys.FromSeed([]byte(req.Secret.InternalData["user_nkey"].(string)))
+	// Auth callout users can only be renewed while the callout still uses them
+	seed, _ := req.Secret.InternalData["user_nkey"].(string)
+	if callout, _ := req.Secret.InternalData["auth_callout"].(bool); callout {
+		name, _ := req.Secret.InternalData["user_name"].(string)
+		if account.AuthCallout == nil || account.AuthCallout.Users[name] != seed {
+			return nil, fmt.Errorf("auth user is no longer configured: %s", name)
+		}
+	}
+
+	userNkey, err := nkeys.FromSeed([]byte(seed))
 	if err != nil {
 		return nil, err
 	}
@@ -340,7 +1070,16 @@ func (ucSvc *UserCredsService) RenewUserCreds(ctx context.Context, req *logical.
 		claims.Name = name
 	}
 
-	userJwt, err := claims.Encode(accountNkey)
+	// Users are renewed by the role they were issued by, since the account's
+	// user signing key would issue them without any permissions
+	signingKey, _ := req.Secret.InternalData["signing_key"].(string)
+	if signingKey != "" {
+		if err := roleSigningKey(account, signingKey); err != nil {
+			return nil, fmt.Errorf("cannot renew user credentials: %w", err)
+		}
+	}
+
+	userJwt, err := issueUserJwt(account, signingKey, claims)
 	if err != nil {
 		return nil, err
 	}
@@ -363,6 +1102,9 @@ func (ucSvc *UserCredsService) RenewUserCreds(ctx context.Context, req *logical.
 		TTL:       ttl,
 		Renewable: true,
 	}
+	if migrated {
+		res.AddWarning(migratedWarning(account))
+	}
 
 	return res, nil
 }
@@ -370,10 +1112,28 @@ func (ucSvc *UserCredsService) RenewUserCreds(ctx context.Context, req *logical.
 // Revoke the specified user credentials. This will add the user's public key
 // to the account JWT's revocation map
 func (ucSvc *UserCredsService) RevokeUserCreds(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	// Deleted accounts have already revoked all of their users
 	accountName := req.Secret.InternalData["account_name"].(string)
 	account, err := getAccount(ctx, req.Storage, accountName)
 	if err != nil {
 		return nil, err
+	} else if account == nil || account.Deleted() {
+		return nil, nil
+	}
+
+	// An account that has been recreated or rotated since doesn't trust the user
+	if ok, err := issuedFor(account, req.Secret); err != nil {
+		return nil, err
+	} else if !ok {
+		ucSvc.Logger.Warn("not revoking user credentials issued for a previous account key", "account", accountName)
+		return nil, nil
+	}
+
+	// Every lease of an auth callout user shares the user's nkey, so revoking it
+	// would also revoke the newer leases. The user is revoked once it's removed
+	// from the auth callout instead.
+	if callout, _ := req.Secret.InternalData["auth_callout"].(bool); callout {
+		return nil, nil
 	}
 
 	userNkey, err := nkeys.FromSeed([]byte(req.Secret.InternalData["user_nkey"].(string)))
@@ -390,17 +1150,33 @@ func (ucSvc *UserCredsService) RevokeUserCreds(ctx context.Context, req *logical
 	}
 	account.Revocations.Revoke(pubKey, time.Now())
 
-	if e, err := logical.StorageEntryJSON(storagePath(accountName), account); err != nil {
-		return nil, err
-	} else if err := req.Storage.Put(ctx, e); err != nil {
+	if err := putAccount(ctx, req.Storage, account); err != nil {
 		return nil, err
 	}
 
 	return nil, nil
 }
 
-// CompactRevocations will revoke all JWTs created for the account in the last hour period. If any
-// tokens were manually revoked already, they will be compacted to reduce the account JWT's size.
+// issuedFor reports whether leased credentials were issued for the account's
+// current identity key. Leases from before the key was recorded are assumed to be.
+func issuedFor(account *Account, secret *logical.Secret) (bool, error) {
+	leaseKey, ok := secret.InternalData["account_public_key"].(string)
+	if !ok {
+		return true, nil
+	}
+
+	pubKey, err := account.PublicKey()
+	if err != nil {
+		return false, err
+	}
+
+	return leaseKey == pubKey, nil
+}
+
+// CompactRevocations compacts the revocations of user JWTs that have expired, to reduce the
+// account JWT's size. Revocations older than the account's max TTL are replaced by a single
+// revocation of all JWTs issued before that time. Accounts without any expired revocations
+// are left unchanged, so their JWTs are only re-issued when something was compacted.
 func (ucSvc *UserCredsService) CompactRevocations(ctx context.Context, req *logical.Request) error {
 	accountNames, err := req.Storage.List(ctx, "accounts/")
 	if err != nil {
@@ -410,23 +1186,56 @@ func (ucSvc *UserCredsService) CompactRevocations(ctx context.Context, req *logi
 	for _, accountName := range accountNames {
 		account, err := getAccount(ctx, req.Storage, accountName)
 		if err != nil {
-			return err
+			ucSvc.Logger.Warn("failed to read account", "account", accountName, "error", err)
+			continue
+		} else if account == nil || account.Deleted() {
+			continue
+		}
+
+		maxTtl := time.Duration(account.MaxTtl) * time.Second
+		if maxTtl == 0 {
+			maxTtl = time.Hour
 		}
 
-		if account.Revocations == nil {
-			account.Revocations = jwt.RevocationList{}
+		// Any JWTs issued before the max TTL will have expired
+		cutoff := time.Now().Add(-maxTtl)
+		if !hasRevocationsBefore(account.Revocations, cutoff) {
+			continue
 		}
 
-		// Any JWTs > 1 hr old will have expired
-		account.Revocations.Revoke(jwt.All, time.Now().Add(-1*time.Hour))
+		account.Revocations.Revoke(jwt.All, cutoff)
 		account.Revocations.MaybeCompact()
 
-		if entry, err := logical.StorageEntryJSON(storagePath(accountName), account); err != nil {
-			return err
-		} else if err := req.Storage.Put(ctx, entry); err != nil {
-			return err
+		if err := putAccount(ctx, req.Storage, account); err != nil {
+			ucSvc.Logger.Warn("failed to compact account revocations", "account", accountName, "error", err)
 		}
 	}
 
 	return nil
 }
+
+// issueUserJwt signs a user's claims with one of the account's signing keys,
+// defaulting to the account's user signing key
+func issueUserJwt(account *Account, signingKey string, claims *jwt.UserClaims) (string, error) {
+	pubKey, err := account.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	claims.IssuerAccount = pubKey
+
+	signer, err := account.UserSigner(signingKey)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Encode(signer)
+}
+
+func hasRevocationsBefore(revocations jwt.RevocationList, t time.Time) bool {
+	for pubKey, ts := range revocations {
+		if pubKey != jwt.All && ts <= t.Unix() {
+			return true
+		}
+	}
+	return false
+}
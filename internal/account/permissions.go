@@ -0,0 +1,282 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/nats-io/jwt/v2"
+)
+
+// permissionFields are the fields used to configure user permissions and limits
+func permissionFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"pub_allow": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "The subjects users are allowed to publish to",
+			Required:    false,
+		},
+		"pub_deny": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "The subjects users aren't allowed to publish to",
+			Required:    false,
+		},
+		"sub_allow": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "The subjects users are allowed to subscribe to",
+			Required:    false,
+		},
+		"sub_deny": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "The subjects users aren't allowed to subscribe to",
+			Required:    false,
+		},
+		"allow_responses": {
+			Type:        framework.TypeBool,
+			Description: "Whether users can publish responses to the reply subjects of requests they receive",
+			Required:    false,
+		},
+		"response_max_msgs": {
+			Type:        framework.TypeInt,
+			Description: "The number of responses users can publish to a reply subject. Defaults to 1",
+			Required:    false,
+		},
+		"response_ttl": {
+			Type:        framework.TypeString,
+			Description: "How long users can publish responses to a reply subject for (i.e. 5s). Unlimited if not set",
+			Required:    false,
+		},
+		"user_max_subscriptions": {
+			Type:        framework.TypeInt64,
+			Description: "The maximum number of subscriptions per user. Unlimited (-1) if not set",
+			Required:    false,
+		},
+		"user_max_payload": {
+			Type:        framework.TypeInt64,
+			Description: "The maximum message payload per user in bytes. Unlimited (-1) if not set",
+			Required:    false,
+		},
+		"user_max_data": {
+			Type:        framework.TypeInt64,
+			Description: "The maximum number of bytes per user. Unlimited (-1) if not set",
+			Required:    false,
+		},
+		"bearer_token": {
+			Type:        framework.TypeBool,
+			Description: "Whether user JWTs can be used without proving possession of the user's nkey",
+			Required:    false,
+		},
+		"allowed_connection_types": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "The connection types users can connect with (i.e. STANDARD, WEBSOCKET, LEAFNODE, MQTT)",
+			Required:    false,
+		},
+		"source_networks": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "The CIDR blocks users can connect from",
+			Required:    false,
+		},
+	}
+}
+
+func withPermissionFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	for k, v := range permissionFields() {
+		fields[k] = v
+	}
+	return fields
+}
+
+// defaultPermissions returns permissions that don't restrict users
+func defaultPermissions() *jwt.UserPermissionLimits {
+	return &jwt.UserPermissionLimits{
+		Limits: jwt.Limits{
+			NatsLimits: jwt.NatsLimits{
+				Subs:    jwt.NoLimit,
+				Data:    jwt.NoLimit,
+				Payload: jwt.NoLimit,
+			},
+		},
+	}
+}
+
+// applyPermissions sets any of the permission fields that were provided, and
+// reports whether any were.
+func applyPermissions(fd *framework.FieldData, perms *jwt.UserPermissionLimits) (bool, error) {
+	changed := false
+	setList := func(field string, list *jwt.StringList) {
+		if v, ok := fd.GetOk(field); ok {
+			*list = v.([]string)
+			changed = true
+		}
+	}
+	setLimit := func(field string, limit *int64) {
+		if v, ok := fd.GetOk(field); ok {
+			*limit = v.(int64)
+			changed = true
+		}
+	}
+
+	setList("pub_allow", &perms.Pub.Allow)
+	setList("pub_deny", &perms.Pub.Deny)
+	setList("sub_allow", &perms.Sub.Allow)
+	setList("sub_deny", &perms.Sub.Deny)
+	setList("allowed_connection_types", &perms.AllowedConnectionTypes)
+
+	setLimit("user_max_subscriptions", &perms.Subs)
+	setLimit("user_max_payload", &perms.Payload)
+	setLimit("user_max_data", &perms.Data)
+
+	if v, ok := fd.GetOk("source_networks"); ok {
+		perms.Src = v.([]string)
+		changed = true
+	}
+
+	if v, ok := fd.GetOk("bearer_token"); ok {
+		perms.BearerToken = v.(bool)
+		changed = true
+	}
+
+	if v, ok := fd.GetOk("allow_responses"); ok {
+		if v.(bool) && perms.Resp == nil {
+			perms.Resp = &jwt.ResponsePermission{MaxMsgs: 1}
+		} else if !v.(bool) {
+			perms.Resp = nil
+		}
+		changed = true
+	}
+
+	if v, ok := fd.GetOk("response_max_msgs"); ok {
+		if perms.Resp == nil {
+			perms.Resp = &jwt.ResponsePermission{}
+		}
+		perms.Resp.MaxMsgs = v.(int)
+		changed = true
+	}
+
+	if v, ok := fd.GetOk("response_ttl"); ok {
+		ttl, err := time.ParseDuration(v.(string))
+		if err != nil {
+			return false, fmt.Errorf("invalid response ttl: %w", err)
+		}
+
+		if perms.Resp == nil {
+			perms.Resp = &jwt.ResponsePermission{MaxMsgs: 1}
+		}
+		perms.Resp.Expires = ttl
+		changed = true
+	}
+
+	return changed, nil
+}
+
+func permissionsData(perms *jwt.UserPermissionLimits) map[string]interface{} {
+	data := map[string]interface{}{
+		"pub_allow":                []string(perms.Pub.Allow),
+		"pub_deny":                 []string(perms.Pub.Deny),
+		"sub_allow":                []string(perms.Sub.Allow),
+		"sub_deny":                 []string(perms.Sub.Deny),
+		"allow_responses":          perms.Resp != nil,
+		"user_max_subscriptions":   perms.Subs,
+		"user_max_payload":         perms.Payload,
+		"user_max_data":            perms.Data,
+		"bearer_token":             perms.BearerToken,
+		"allowed_connection_types": []string(perms.AllowedConnectionTypes),
+		"source_networks":          []string(perms.Src),
+	}
+
+	if perms.Resp != nil {
+		data["response_max_msgs"] = perms.Resp.MaxMsgs
+		data["response_ttl"] = perms.Resp.Expires.String()
+	}
+
+	return data
+}
+
+// applyDefaultPermissions sets the permissions of users whose JWTs don't
+// specify any permissions of their own. The permissions are replaced as a
+// whole, and are removed if none are given.
+func applyDefaultPermissions(fd *framework.FieldData, account *Account) error {
+	v, ok := fd.GetOk("default_permissions")
+	if !ok {
+		return nil
+	}
+
+	fields := v.(map[string]interface{})
+	if len(fields) == 0 {
+		account.DefaultPermissions = nil
+		return nil
+	}
+
+	perms := new(jwt.Permissions)
+	for field, value := range fields {
+		var err error
+		switch field {
+		case "pub_allow":
+			perms.Pub.Allow, err = stringList(value)
+		case "pub_deny":
+			perms.Pub.Deny, err = stringList(value)
+		case "sub_allow":
+			perms.Sub.Allow, err = stringList(value)
+		case "sub_deny":
+			perms.Sub.Deny, err = stringList(value)
+		case "allow_responses":
+			if allow, isBool := value.(bool); !isBool {
+				err = errors.New("expected a boolean")
+			} else if allow {
+				perms.Resp = &jwt.ResponsePermission{MaxMsgs: 1}
+			}
+		default:
+			return fmt.Errorf("unknown default permission: %s", field)
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid default permission %s: %w", field, err)
+		}
+	}
+
+	account.DefaultPermissions = perms
+	return nil
+}
+
+// stringList reads a list of subjects from a map field, which may be a list or
+// a comma separated string.
+func stringList(value interface{}) (jwt.StringList, error) {
+	switch v := value.(type) {
+	case string:
+		list := jwt.StringList{}
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		return list, nil
+	case []interface{}:
+		list := make(jwt.StringList, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("expected a list of strings")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, errors.New("expected a list of strings")
+	}
+}
+
+func defaultPermissionsData(perms *jwt.Permissions) map[string]interface{} {
+	if perms == nil {
+		perms = new(jwt.Permissions)
+	}
+
+	return map[string]interface{}{
+		"pub_allow":       []string(perms.Pub.Allow),
+		"pub_deny":        []string(perms.Pub.Deny),
+		"sub_allow":       []string(perms.Sub.Allow),
+		"sub_deny":        []string(perms.Sub.Deny),
+		"allow_responses": perms.Resp != nil,
+	}
+}
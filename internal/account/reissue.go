@@ -0,0 +1,176 @@
+package account
+
+import (
+	"context"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/operator"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// AccountNames lists the accounts that are issued by the named operator
+func (svc *Service) AccountNames(ctx context.Context, s logical.Storage, opName string) ([]string, error) {
+	return accountNames(ctx, s, func(account *Account) bool {
+		return account.Operator == opName
+	})
+}
+
+// SigningKeyAccounts lists the accounts bound to the named operator that are
+// issued by one of its signing keys
+func (svc *Service) SigningKeyAccounts(ctx context.Context, s logical.Storage, opName, signingKey string) ([]string, error) {
+	return accountNames(ctx, s, func(account *Account) bool {
+		return account.Operator == opName && account.OperatorSigningKey == signingKey
+	})
+}
+
+// accountNames lists the accounts that haven't been deleted and match the filter
+func accountNames(ctx context.Context, s logical.Storage, filter func(account *Account) bool) ([]string, error) {
+	accountNames, err := s.List(ctx, "accounts/")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(accountNames))
+	for _, accountName := range accountNames {
+		account, err := getAccount(ctx, s, accountName)
+		if err != nil {
+			return nil, err
+		} else if account != nil && !account.Deleted() && filter(account) {
+			names = append(names, accountName)
+		}
+	}
+
+	return names, nil
+}
+
+// ReissueAccounts re-signs the JWT of every account issued by the named operator.
+// Accounts that can't be signed are reported with an error, rather than aborting
+// the others.
+func (svc *Service) ReissueAccounts(ctx context.Context, s logical.Storage, opName string) (map[string]operator.ReissuedAccount, error) {
+	accountNames, err := svc.AccountNames(ctx, s, opName)
+	if err != nil {
+		return nil, err
+	}
+
+	reissued := make(map[string]operator.ReissuedAccount, len(accountNames))
+	for _, accountName := range accountNames {
+		account, err := getAccount(ctx, s, accountName)
+		if err != nil {
+			return nil, err
+		}
+
+		pubKey, err := account.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := putAccount(ctx, s, account); err != nil {
+			svc.Logger.Warn("failed to reissue account JWT", "account", accountName, "error", err)
+			reissued[accountName] = operator.ReissuedAccount{PublicKey: pubKey, Error: err.Error()}
+			continue
+		}
+
+		reissued[accountName] = operator.ReissuedAccount{
+			PublicKey: pubKey,
+			Jwt:       account.Jwt,
+		}
+	}
+
+	return reissued, nil
+}
+
+// AccountJwts returns the JWTs of every account issued by the named operator,
+// keyed by the account's public key.
+func (svc *Service) AccountJwts(ctx context.Context, s logical.Storage, opName string) (map[string]string, error) {
+	accountNames, err := svc.AccountNames(ctx, s, opName)
+	if err != nil {
+		return nil, err
+	}
+
+	jwts := make(map[string]string, len(accountNames))
+	for _, accountName := range accountNames {
+		account, err := getAccount(ctx, s, accountName)
+		if err != nil {
+			return nil, err
+		}
+
+		// The stored JWT is only replaced if it's no longer current, and isn't
+		// persisted, as this is used to serve reads
+		if err := issueJwt(ctx, s, account); err != nil {
+			return nil, err
+		}
+
+		pubKey, err := account.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+
+		jwts[pubKey] = account.Jwt
+	}
+
+	return jwts, nil
+}
+
+// ReissueExpiringJwts re-signs account JWTs that are close to expiring
+func (svc *Service) ReissueExpiringJwts(ctx context.Context, req *logical.Request) error {
+	accountNames, err := req.Storage.List(ctx, "accounts/")
+	if err != nil {
+		return err
+	}
+
+	for _, accountName := range accountNames {
+		account, err := getAccount(ctx, req.Storage, accountName)
+		if err != nil {
+			svc.Logger.Warn("failed to read account", "account", accountName, "error", err)
+			continue
+		} else if account == nil || account.Deleted() || account.JwtTtl == 0 || account.Jwt == "" {
+			continue
+		}
+
+		previous := account.Jwt
+		if err := issueJwt(ctx, req.Storage, account); err != nil {
+			svc.Logger.Warn("failed to reissue account JWT", "account", accountName, "error", err)
+			continue
+		} else if account.Jwt == previous {
+			continue
+		}
+
+		if err := putAccount(ctx, req.Storage, account); err != nil {
+			svc.Logger.Warn("failed to reissue account JWT", "account", accountName, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// updateAccounts applies an update to every other account in the mount, and
+// re-issues the JWTs of the accounts it changed, which are returned. Accounts
+// that can't be read or re-issued are logged, rather than aborting the others.
+func (svc *Service) updateAccounts(ctx context.Context, s logical.Storage, skip string, update func(account *Account) bool) ([]string, error) {
+	accountNames, err := s.List(ctx, "accounts/")
+	if err != nil {
+		return nil, err
+	}
+
+	updated := make([]string, 0)
+	for _, accountName := range accountNames {
+		if accountName == skip {
+			continue
+		}
+
+		account, err := getAccount(ctx, s, accountName)
+		if err != nil {
+			svc.Logger.Warn("failed to read account", "account", accountName, "error", err)
+			continue
+		} else if account == nil || account.Deleted() || !update(account) {
+			continue
+		}
+
+		if err := putAccount(ctx, s, account); err != nil {
+			svc.Logger.Warn("failed to reissue account JWT", "account", accountName, "error", err)
+			continue
+		}
+		updated = append(updated, accountName)
+	}
+
+	return updated, nil
+}
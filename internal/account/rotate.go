@@ -0,0 +1,240 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/nkutil"
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/operator"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/nkeys"
+)
+
+// Rotate replaces the account's identity key, and re-issues its JWT. Users that
+// were issued for the previous identity are no longer valid once servers stop
+// trusting the previous account JWT, which the returned deletion record removes
+// from resolvers. Imports of the account's exports by other accounts in the
+// mount are updated to refer to the new identity.
+func (svc *Service) Rotate(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	prevPubKey, err := account.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	nk, err := nkutil.GetOrDefault(fd, "nkey", nkeys.CreateAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	} else if !nkeys.IsValidPublicAccountKey(pubKey) {
+		return nil, errors.New("account key must be an account nkey")
+	} else if pubKey == prevPubKey {
+		return nil, errors.New("account key must be different from the current key")
+	}
+
+	seed, err := nk.Seed()
+	if err != nil {
+		return nil, err
+	}
+
+	account.Nkey = string(seed)
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	op, err := operator.GetOperator(ctx, req.Storage, account.Operator)
+	if err != nil {
+		return nil, err
+	} else if op != nil && op.SystemAccount == prevPubKey {
+		if err := operator.SetSystemAccount(ctx, req.Storage, account.Operator, pubKey); err != nil {
+			return nil, err
+		}
+	}
+
+	// The system account check passes now that the operator refers to the new key
+	deletionJwt, err := deletionRecord(ctx, req.Storage, account, prevPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	importers, err := svc.updateImporters(ctx, req.Storage, account, prevPubKey, pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := accountData(account)
+	if err != nil {
+		return nil, err
+	}
+	data["name"] = account.Name
+	data["previous_public_key"] = prevPubKey
+	data["deletion_jwt"] = deletionJwt
+	data["importers"] = importers
+
+	return &logical.Response{Data: data}, nil
+}
+
+// updateImporters re-resolves the imports of accounts that import from the
+// rotated account, and re-issues their JWTs. Accounts that can't be updated are
+// logged, rather than aborting the others.
+func (svc *Service) updateImporters(ctx context.Context, s logical.Storage, exporter *Account, prevPubKey, pubKey string) ([]string, error) {
+	return svc.updateAccounts(ctx, s, exporter.Name, func(account *Account) bool {
+		changed := false
+		for name, imp := range account.Imports {
+			if imp.Account != exporter.Name {
+				continue
+			}
+
+			if err := resolveImport(ctx, s, account, imp); err != nil {
+				svc.Logger.Warn("failed to update import", "account", account.Name, "import", name, "error", err)
+				continue
+			}
+			changed = true
+		}
+
+		if account.AuthCallout != nil {
+			for i, allowed := range account.AuthCallout.AllowedAccounts {
+				if allowed == prevPubKey {
+					account.AuthCallout.AllowedAccounts[i] = pubKey
+					changed = true
+				}
+			}
+		}
+
+		return changed
+	})
+}
+
+// RotateSigningKey replaces a signing key with a new key of the same name, so
+// the new key issues user credentials that would have been issued by the old
+// one. The old key is kept in the account JWT until all user credentials it
+// issued have expired, based on the account's max TTL.
+func (svc *Service) RotateSigningKey(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fd.Get("key").(string)
+	prevSeed, ok := account.SigningKeys[name]
+	if !ok {
+		return nil, fmt.Errorf("account signing key not found: %s", name)
+	}
+
+	prevNkey, err := nkeys.FromSeed([]byte(prevSeed))
+	if err != nil {
+		return nil, err
+	}
+
+	prevPubKey, err := prevNkey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	nk, err := nkeys.CreateAccount()
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := nk.Seed()
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	grace := time.Duration(account.MaxTtl) * time.Second
+	if grace == 0 {
+		grace = time.Hour
+	}
+
+	now := time.Now()
+	retiredKey := fmt.Sprintf("%s-rotated-%d", name, now.UnixNano())
+	if _, ok := account.SigningKeys[retiredKey]; ok {
+		return nil, fmt.Errorf("account signing key already exists: %s", retiredKey)
+	}
+	expiresAt := now.Add(grace).Unix()
+
+	account.SigningKeys[retiredKey] = prevSeed
+	account.SigningKeys[name] = string(seed)
+
+	// Users issued by a scoped key keep their permissions until the retired key expires
+	if scope, ok := account.SigningKeyScopes[name]; ok {
+		retiredScope := *scope
+		account.SigningKeyScopes[retiredKey] = &retiredScope
+	}
+
+	if account.SigningKeyExpirations == nil {
+		account.SigningKeyExpirations = make(map[string]int64)
+	}
+	account.SigningKeyExpirations[retiredKey] = expiresAt
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":                name,
+			"public_key":          pubKey,
+			"previous_public_key": prevPubKey,
+			"retired_signing_key": retiredKey,
+			"retired_expires_at":  expiresAt,
+			"account_jwt":         account.Jwt,
+		},
+	}, nil
+}
+
+// ExpireSigningKeys removes signing keys that were retained after a rotation
+// once the user credentials they issued have expired.
+func (svc *Service) ExpireSigningKeys(ctx context.Context, req *logical.Request) error {
+	accountNames, err := req.Storage.List(ctx, "accounts/")
+	if err != nil {
+		return err
+	}
+
+	for _, accountName := range accountNames {
+		account, err := getAccount(ctx, req.Storage, accountName)
+		if err != nil {
+			svc.Logger.Warn("failed to read account", "account", accountName, "error", err)
+			continue
+		} else if account == nil || account.Deleted() {
+			continue
+		}
+
+		now := time.Now().Unix()
+		expired := false
+		for name, expiry := range account.SigningKeyExpirations {
+			if expiry <= now {
+				delete(account.SigningKeys, name)
+				delete(account.SigningKeyScopes, name)
+				delete(account.SigningKeyExpirations, name)
+				expired = true
+			}
+		}
+
+		if !expired {
+			continue
+		}
+
+		if err := putAccount(ctx, req.Storage, account); err != nil {
+			svc.Logger.Warn("failed to expire account signing keys", "account", accountName, "error", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/nkutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// defaultSigningKey is the name of the signing key that is created for
+// accounts that don't have a signing key to issue user credentials with
+const defaultSigningKey = "default"
+
+func (svc *Service) ListSigningKeys(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(signingKeyNames(account)), nil
+}
+
+// WriteSigningKey adds a signing key to the account, and returns the updated
+// account JWT, which will include the signing key's public key.
+func (svc *Service) WriteSigningKey(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fd.Get("key").(string)
+	if name == "" {
+		return nil, errors.New("signing key cannot have empty name")
+	}
+
+	nk, err := nkutil.GetOrDefault(fd, "nkey", func() (nkeys.KeyPair, error) {
+		if seed, ok := account.SigningKeys[name]; ok {
+			return nkeys.FromSeed([]byte(seed))
+		}
+		return nkeys.CreateAccount()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	} else if !nkeys.IsValidPublicAccountKey(pubKey) {
+		return nil, errors.New("signing key must be an account nkey")
+	}
+
+	seed, err := nk.Seed()
+	if err != nil {
+		return nil, err
+	}
+
+	if account.SigningKeys == nil {
+		account.SigningKeys = make(map[string]string)
+	}
+	account.SigningKeys[name] = string(seed)
+
+	if err := applyScope(fd, account, name); err != nil {
+		return nil, err
+	}
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	data := signingKeyData(account, name)
+	data["public_key"] = pubKey
+	data["account_jwt"] = account.Jwt
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (svc *Service) ReadSigningKey(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fd.Get("key").(string)
+	seed, ok := account.SigningKeys[name]
+	if !ok {
+		return nil, nil
+	}
+
+	nk, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	data := signingKeyData(account, name)
+	data["public_key"] = pubKey
+
+	return &logical.Response{Data: data}, nil
+}
+
+// DeleteSigningKey removes a signing key from the account. User credentials that
+// were issued by the signing key will no longer be accepted by NATS servers once
+// they receive the updated account JWT.
+func (svc *Service) DeleteSigningKey(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	account, err := requireAccount(ctx, req.Storage, fd.Get("account_name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fd.Get("key").(string)
+	if _, ok := account.SigningKeys[name]; !ok {
+		return nil, nil
+	} else if name == account.UserSigningKey {
+		return nil, errors.New("cannot delete the signing key that issues user credentials")
+	}
+	delete(account.SigningKeys, name)
+	delete(account.SigningKeyScopes, name)
+	delete(account.SigningKeyExpirations, name)
+
+	if err := putAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// UserSigner returns the signing key that user credentials are issued by. If no
+// signing key name is given, the account's user signing key is used.
+func (a *Account) UserSigner(signingKey string) (nkeys.KeyPair, error) {
+	if signingKey == "" {
+		signingKey = a.UserSigningKey
+	}
+
+	seed, ok := a.SigningKeys[signingKey]
+	if !ok {
+		return nil, fmt.Errorf("account signing key not found: %s", signingKey)
+	}
+
+	return nkeys.FromSeed([]byte(seed))
+}
+
+// roleSigningKey checks that a signing key requested to issue user credentials
+// is a scoped signing key, so the user's permissions come from its role. Keys
+// retired by a rotation are only kept until the users they issued expire.
+func roleSigningKey(account *Account, name string) error {
+	if _, ok := account.SigningKeys[name]; !ok {
+		return fmt.Errorf("account signing key not found: %s", name)
+	} else if _, ok := account.SigningKeyScopes[name]; !ok {
+		return fmt.Errorf("account signing key is not scoped: %s", name)
+	} else if _, ok := account.SigningKeyExpirations[name]; ok {
+		return fmt.Errorf("account signing key has been retired: %s", name)
+	}
+	return nil
+}
+
+// ensureUserSigningKey creates the default signing key for accounts that don't
+// have a signing key to issue user credentials with, so the account's identity
+// key is never used to issue them.
+func ensureUserSigningKey(account *Account) error {
+	if _, ok := account.SigningKeys[account.UserSigningKey]; ok {
+		return nil
+	}
+
+	if _, ok := account.SigningKeys[defaultSigningKey]; !ok {
+		nk, err := nkeys.CreateAccount()
+		if err != nil {
+			return err
+		}
+
+		seed, err := nk.Seed()
+		if err != nil {
+			return err
+		}
+
+		if account.SigningKeys == nil {
+			account.SigningKeys = make(map[string]string)
+		}
+		account.SigningKeys[defaultSigningKey] = string(seed)
+	}
+
+	account.UserSigningKey = defaultSigningKey
+	return nil
+}
+
+// applyScope configures whether the signing key is scoped. Users issued by a
+// scoped signing key get their permissions and limits from the scope's template,
+// and the permissions can't be changed without changing the account JWT.
+func applyScope(fd *framework.FieldData, account *Account, name string) error {
+	if scoped, ok := fd.GetOk("scoped"); ok && !scoped.(bool) {
+		delete(account.SigningKeyScopes, name)
+		return nil
+	}
+
+	scope, ok := account.SigningKeyScopes[name]
+	if !ok {
+		scope = jwt.NewUserScope()
+	}
+
+	changed, err := applyPermissions(fd, &scope.Template)
+	if err != nil {
+		return err
+	}
+
+	if role, ok := fd.GetOk("role"); ok {
+		scope.Role = role.(string)
+		changed = true
+	}
+
+	if scoped, ok := fd.GetOk("scoped"); ok && scoped.(bool) {
+		changed = true
+	}
+
+	if changed {
+		if account.SigningKeyScopes == nil {
+			account.SigningKeyScopes = make(map[string]*jwt.UserScope)
+		}
+		account.SigningKeyScopes[name] = scope
+	}
+
+	return nil
+}
+
+func signingKeyData(account *Account, name string) map[string]interface{} {
+	data := map[string]interface{}{
+		"name":       name,
+		"user_creds": name == account.UserSigningKey,
+		"scoped":     false,
+	}
+
+	if expiry, ok := account.SigningKeyExpirations[name]; ok {
+		data["expires_at"] = expiry
+	}
+
+	if scope, ok := account.SigningKeyScopes[name]; ok {
+		for k, v := range permissionsData(&scope.Template) {
+			data[k] = v
+		}
+		data["scoped"] = true
+		data["role"] = scope.Role
+	}
+
+	return data
+}
+
+func signingKeyNames(account *Account) []string {
+	return sortedKeys(account.SigningKeys)
+}
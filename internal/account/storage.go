@@ -2,10 +2,16 @@ package account
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/jwtutil"
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/operator"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
 )
 
 func storagePath(name string) string {
@@ -15,9 +21,43 @@ func storagePath(name string) string {
 type Account struct {
 	Name        string             `json:"name"`
 	Nkey        string             `json:"nkey"`
+	Jwt         string             `json:"jwt,omitempty"`
 	Revocations jwt.RevocationList `json:"revocations,omitempty"`
 	DefaultTtl  int                `json:"default_ttl,omitempty"`
 	MaxTtl      int                `json:"max_ttl,omitempty"`
+	JwtTtl      int                `json:"jwt_ttl,omitempty"`
+	Description string             `json:"description,omitempty"`
+	InfoUrl     string             `json:"info_url,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+
+	// SigningKeys are the account's signing keys (name -> seed). User credentials
+	// are issued by the UserSigningKey rather than the account's identity key.
+	SigningKeys    map[string]string `json:"signing_keys,omitempty"`
+	UserSigningKey string            `json:"user_signing_key,omitempty"`
+
+	// SigningKeyScopes holds the scopes of scoped signing keys, by signing key name
+	SigningKeyScopes map[string]*jwt.UserScope `json:"signing_key_scopes,omitempty"`
+
+	// SigningKeyExpirations holds the time at which retired signing keys are removed
+	SigningKeyExpirations map[string]int64 `json:"signing_key_expirations,omitempty"`
+
+	Operator           string `json:"operator,omitempty"`
+	OperatorSigningKey string `json:"operator_signing_key,omitempty"`
+
+	Limits   *jwt.OperatorLimits    `json:"limits,omitempty"`
+	Exports  map[string]*jwt.Export `json:"exports,omitempty"`
+	Imports  map[string]*Import     `json:"imports,omitempty"`
+	Mappings jwt.Mapping            `json:"mappings,omitempty"`
+
+	// DefaultPermissions apply to users that don't have any permissions of their own
+	DefaultPermissions *jwt.Permissions `json:"default_permissions,omitempty"`
+
+	AuthCallout *AuthCallout `json:"auth_callout,omitempty"`
+
+	// DeletedAt is set when the account is deleted, along with a request to
+	// delete it from NATS resolvers that is signed by the operator
+	DeletedAt   int64  `json:"deleted_at,omitempty"`
+	DeletionJwt string `json:"deletion_jwt,omitempty"`
 }
 
 func getAccount(ctx context.Context, s logical.Storage, name string) (*Account, error) {
@@ -35,5 +75,190 @@ func getAccount(ctx context.Context, s logical.Storage, name string) (*Account,
 		return nil, fmt.Errorf("error reading account: %w", err)
 	}
 
+	// Older accounts were stored without their name
+	if config.Name == "" {
+		config.Name = name
+	}
+
 	return config, nil
 }
+
+// requireAccount reads the named account, returning an error if it doesn't exist
+func requireAccount(ctx context.Context, s logical.Storage, name string) (*Account, error) {
+	if name == "" {
+		return nil, errors.New("account name cannot be empty")
+	}
+
+	account, err := getAccount(ctx, s, name)
+	if err != nil {
+		return nil, err
+	} else if account == nil {
+		return nil, fmt.Errorf("account not found: %s", name)
+	} else if account.Deleted() {
+		return nil, fmt.Errorf("account has been deleted: %s", name)
+	}
+
+	return account, nil
+}
+
+// PublicKey returns the public key of the account's identity key
+func (a *Account) PublicKey() (string, error) {
+	nk, err := nkeys.FromSeed([]byte(a.Nkey))
+	if err != nil {
+		return "", err
+	}
+	return nk.PublicKey()
+}
+
+// putAccount issues the account's JWT and persists the account
+func putAccount(ctx context.Context, s logical.Storage, account *Account) error {
+	if err := ensureUserSigningKey(account); err != nil {
+		return err
+	}
+
+	if err := issueJwt(ctx, s, account); err != nil {
+		return err
+	}
+
+	if entry, err := logical.StorageEntryJSON(storagePath(account.Name), account); err != nil {
+		return err
+	} else if err := s.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// issueJwt builds the account's claims and signs them with the operator key
+// that the account is configured to be issued by. The previously issued JWT
+// is kept if the claims haven't changed.
+func issueJwt(ctx context.Context, s logical.Storage, account *Account) error {
+	pubKey, err := account.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	claims := jwt.NewAccountClaims(pubKey)
+	claims.Name = account.Name
+	claims.Revocations = account.Revocations
+	claims.Description = account.Description
+	claims.InfoURL = account.InfoUrl
+	claims.Tags.Add(account.Tags...)
+
+	if account.Limits != nil {
+		claims.Limits = *account.Limits
+	}
+
+	if account.DefaultPermissions != nil {
+		claims.DefaultPermissions = *account.DefaultPermissions
+	}
+
+	for _, name := range signingKeyNames(account) {
+		nk, err := nkeys.FromSeed([]byte(account.SigningKeys[name]))
+		if err != nil {
+			return err
+		}
+
+		signingKey, err := nk.PublicKey()
+		if err != nil {
+			return err
+		}
+
+		if scope, ok := account.SigningKeyScopes[name]; ok {
+			scoped := *scope
+			scoped.Key = signingKey
+			claims.SigningKeys.AddScopedSigner(&scoped)
+		} else {
+			claims.SigningKeys.Add(signingKey)
+		}
+	}
+
+	// Exports and imports are added in name order, so that claims with the
+	// same subject are always encoded in the same order
+	for _, name := range sortedKeys(account.Exports) {
+		claims.Exports.Add(account.Exports[name])
+	}
+
+	for _, name := range sortedKeys(account.Imports) {
+		claim := account.Imports[name].Claim
+		claims.Imports.Add(&claim)
+	}
+
+	if account.AuthCallout != nil {
+		auth, err := account.AuthCallout.authorization()
+		if err != nil {
+			return err
+		}
+		claims.Authorization = auth
+	}
+
+	for subject, mappings := range account.Mappings {
+		claims.AddMapping(subject, mappings...)
+	}
+
+	if err := jwtutil.Validate(claims); err != nil {
+		return err
+	}
+
+	op, err := operator.GetOperator(ctx, s, account.Operator)
+	if err != nil {
+		return err
+	} else if op == nil && account.Operator != "" {
+		return fmt.Errorf("operator not found: %s", account.Operator)
+	} else if op == nil {
+		return errors.New("operator has not been configured")
+	}
+
+	signer, err := op.AccountSigner(account.OperatorSigningKey)
+	if err != nil {
+		return err
+	}
+
+	accountJwt, err := jwtutil.Issue(claims, signer, account.Jwt, time.Duration(account.JwtTtl)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	account.Jwt = accountJwt
+	return nil
+}
+
+func accountData(account *Account) (map[string]interface{}, error) {
+	pubKey, err := account.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"public_key":           pubKey,
+		"jwt":                  account.Jwt,
+		"jwt_hash":             jwtutil.Hash(account.Jwt),
+		"default_ttl":          account.DefaultTtl,
+		"max_ttl":              account.MaxTtl,
+		"jwt_ttl":              account.JwtTtl,
+		"description":          account.Description,
+		"info_url":             account.InfoUrl,
+		"tags":                 account.Tags,
+		"operator":             account.Operator,
+		"operator_signing_key": account.OperatorSigningKey,
+		"user_signing_key":     account.UserSigningKey,
+	}
+
+	for k, v := range limitsData(account.Limits) {
+		data[k] = v
+	}
+
+	data["default_permissions"] = defaultPermissionsData(account.DefaultPermissions)
+
+	return data, nil
+}
+
+// sortedKeys returns the keys of the map in sorted order
+func sortedKeys[K ~string, V any](m map[K]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	return keys
+}
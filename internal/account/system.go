@@ -0,0 +1,94 @@
+package account
+
+import (
+	"context"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/operator"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+const systemAccountName = "SYS"
+
+// systemExports are the exports nats-server expects the system account to
+// provide, so that accounts can import their own monitoring services.
+func systemExports() map[string]*jwt.Export {
+	infoUrl := "https://docs.nats.io/nats-server/configuration/sys_accounts"
+
+	return map[string]*jwt.Export{
+		"account-monitoring-services": {
+			Name:                 "account-monitoring-services",
+			Subject:              "$SYS.REQ.ACCOUNT.*.*",
+			Type:                 jwt.Service,
+			ResponseType:         jwt.ResponseTypeStream,
+			AccountTokenPosition: 4,
+			Info: jwt.Info{
+				Description: "Request account specific monitoring services for: SUBSZ, CONNZ, LEAFZ, JSZ and INFO",
+				InfoURL:     infoUrl,
+			},
+		},
+		"account-monitoring-streams": {
+			Name:                 "account-monitoring-streams",
+			Subject:              "$SYS.ACCOUNT.*.>",
+			Type:                 jwt.Stream,
+			AccountTokenPosition: 3,
+			Info: jwt.Info{
+				Description: "Account specific monitoring stream",
+				InfoURL:     infoUrl,
+			},
+		},
+	}
+}
+
+// InitSystemAccount creates the SYS account, and registers it as the default
+// operator's system account. Nothing is changed if the operator already has a system account.
+func (svc *Service) InitSystemAccount(ctx context.Context, req *logical.InitializationRequest) error {
+	op, err := operator.GetOperator(ctx, req.Storage, "")
+	if err != nil {
+		return err
+	} else if op == nil || op.SystemAccount != "" {
+		return nil
+	}
+
+	account, err := getAccount(ctx, req.Storage, systemAccountName)
+	if err != nil {
+		return err
+	}
+
+	if account == nil {
+		accountNkey, err := nkeys.CreateAccount()
+		if err != nil {
+			return err
+		}
+
+		accountSeed, err := accountNkey.Seed()
+		if err != nil {
+			return err
+		}
+
+		account = &Account{
+			Name:       systemAccountName,
+			Nkey:       string(accountSeed),
+			DefaultTtl: 15 * 60,
+			MaxTtl:     60 * 60,
+			Exports:    systemExports(),
+		}
+
+		if err := putAccount(ctx, req.Storage, account); err != nil {
+			return err
+		}
+	}
+
+	accountNkey, err := nkeys.FromSeed([]byte(account.Nkey))
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := accountNkey.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	return operator.SetSystemAccount(ctx, req.Storage, "", pubKey)
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/nkeys"
+)
+
+func TestAccountWritesKeepNkey(t *testing.T) {
+	tb := newTestBackend(t)
+
+	created := tb.write("accounts/APP", nil)
+	pubKey := created.Data["public_key"].(string)
+
+	updated := tb.write("accounts/APP", map[string]interface{}{"description": "orders"})
+	if updated.Data["public_key"] != pubKey {
+		t.Errorf("update changed the account key from %s to %s", pubKey, updated.Data["public_key"])
+	}
+
+	patched, err := tb.request(logical.PatchOperation, "accounts/APP", map[string]interface{}{"default_ttl": "5m"})
+	if err != nil {
+		t.Fatal(err)
+	} else if patched.Data["public_key"] != pubKey {
+		t.Errorf("patch changed the account key from %s to %s", pubKey, patched.Data["public_key"])
+	} else if patched.Data["description"] != "orders" {
+		t.Errorf("patch cleared the account description: %v", patched.Data["description"])
+	}
+
+	nk, err := nkeys.CreateAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, err := nk.Seed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tb.request(logical.UpdateOperation, "accounts/APP", map[string]interface{}{"nkey": string(seed)}); err == nil {
+		t.Error("expected an error replacing the account key")
+	}
+
+	if read := tb.read("accounts/APP"); read.Data["public_key"] != pubKey {
+		t.Errorf("account key changed from %s to %s", pubKey, read.Data["public_key"])
+	}
+}
+
+func TestDeletedAccountLifecycle(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("accounts/APP", nil)
+	lease := tb.read("accounts/APP/user-creds").Secret
+
+	deleted, err := tb.request(logical.DeleteOperation, "accounts/APP", nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if deleted.Data["deletion_jwt"] == "" {
+		t.Error("expected a deletion record")
+	}
+
+	// The tombstone can't be replaced while leases of its credentials remain
+	if _, err := tb.request(logical.UpdateOperation, "accounts/APP", nil); err == nil || !strings.Contains(err.Error(), "deleted") {
+		t.Errorf("expected an error recreating the deleted account, got: %v", err)
+	}
+
+	if _, err := tb.secret(logical.RenewOperation, lease); err == nil {
+		t.Error("expected an error renewing credentials of a deleted account")
+	}
+
+	tb.edit("accounts/APP", func(entry map[string]interface{}) {
+		entry["deleted_at"] = time.Now().Add(-2 * time.Hour).Unix()
+	})
+	tb.periodic()
+
+	if res, err := tb.request(logical.ReadOperation, "accounts/APP", nil); err != nil {
+		t.Fatal(err)
+	} else if res != nil {
+		t.Fatal("expected the deleted account to be purged")
+	}
+
+	recreated := tb.write("accounts/APP", nil)
+
+	if _, err := tb.secret(logical.RenewOperation, lease); err == nil {
+		t.Error("expected an error renewing credentials of the previous account")
+	}
+
+	if _, err := tb.secret(logical.RevokeOperation, lease); err != nil {
+		t.Fatal(err)
+	}
+
+	if claims := accountClaims(t, tb.read("accounts/APP")); len(claims.Revocations) != 0 {
+		t.Errorf("revoking credentials of the previous account revoked users of the new one: %v", claims.Revocations)
+	} else if claims.Subject != recreated.Data["public_key"] {
+		t.Errorf("expected account %s, got %s", recreated.Data["public_key"], claims.Subject)
+	}
+}
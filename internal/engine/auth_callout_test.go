@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestAuthCalloutLeasesShareUser(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("accounts/AUTH", nil)
+	tb.write("accounts/AUTH/auth-callout", map[string]interface{}{"auth_users": "callout,backup"})
+
+	first := tb.read("accounts/AUTH/auth-callout/creds/callout")
+	second := tb.read("accounts/AUTH/auth-callout/creds/callout")
+	pubKey := first.Data["public_key"].(string)
+
+	// An expiring lease doesn't revoke the user the newer lease is using
+	if _, err := tb.secret(logical.RevokeOperation, first.Secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, revoked := accountClaims(t, tb.read("accounts/AUTH")).Revocations[pubKey]; revoked {
+		t.Error("revoking a lease revoked the auth user shared with other leases")
+	}
+	if _, err := tb.secret(logical.RenewOperation, second.Secret); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the user from the auth callout revokes it
+	tb.write("accounts/AUTH/auth-callout", map[string]interface{}{"auth_users": "backup"})
+	if _, revoked := accountClaims(t, tb.read("accounts/AUTH")).Revocations[pubKey]; !revoked {
+		t.Error("expected the removed auth user to be revoked")
+	}
+	if _, err := tb.secret(logical.RenewOperation, second.Secret); err == nil {
+		t.Error("expected an error renewing credentials of a removed auth user")
+	}
+}
@@ -1,10 +1,12 @@
 package engine
 
 import (
+	"context"
 	"strings"
 
 	"github.com/egoodhall/vault-secrets-engine-nats/internal/account"
 	"github.com/egoodhall/vault-secrets-engine-nats/internal/operator"
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -15,18 +17,41 @@ After mounting this backend, credentials to manage NATS credentials
 must be configured with the "config/" endpoints.
 `
 
-func NewNatsEngine(opsvc *operator.Service, arsvc *account.UserCredsService, paths []*framework.Path, secrets []*framework.Secret) *framework.Backend {
+func NewNatsEngine(opsvc *operator.Service, acsvc *account.Service, arsvc *account.UserCredsService, paths []*framework.Path, secrets []*framework.Secret) *framework.Backend {
 	return &framework.Backend{
-		BackendType:    logical.TypeLogical,
-		Help:           strings.TrimSpace(backendHelp),
-		InitializeFunc: opsvc.InitOperator,
-		PeriodicFunc:   arsvc.CompactRevocations,
-		Secrets:        secrets,
-		Paths:          paths,
+		BackendType: logical.TypeLogical,
+		Help:        strings.TrimSpace(backendHelp),
+		InitializeFunc: func(ctx context.Context, req *logical.InitializationRequest) error {
+			if err := opsvc.InitOperator(ctx, req); err != nil {
+				return err
+			}
+			return acsvc.InitSystemAccount(ctx, req)
+		},
+		PeriodicFunc: func(ctx context.Context, req *logical.Request) error {
+			// Each task runs even if an earlier one fails, so a single broken
+			// operator or account can't stop the others from being maintained
+			var errs *multierror.Error
+			for _, task := range []func(context.Context, *logical.Request) error{
+				opsvc.ExpireSigningKeys,
+				opsvc.ReissueExpiringJwts,
+				acsvc.ExpireSigningKeys,
+				acsvc.ReissueExpiringJwts,
+				arsvc.CompactRevocations,
+				acsvc.PurgeDeletedAccounts,
+			} {
+				if err := task(ctx, req); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			}
+			return errs.ErrorOrNil()
+		},
+		Secrets: secrets,
+		Paths:   paths,
 		PathsSpecial: &logical.Paths{
 			LocalStorage: make([]string, 0),
 			SealWrapStorage: []string{
 				"operator/*",
+				"operators/*",
 				"account/*",
 			},
 		},
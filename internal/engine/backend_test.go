@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+)
+
+// testBackend is an initialized backend mounted at nats/, using in-memory storage
+type testBackend struct {
+	t       *testing.T
+	backend logical.Backend
+	storage logical.Storage
+}
+
+func newTestBackend(t *testing.T) *testBackend {
+	t.Helper()
+
+	ctx := context.Background()
+	storage := new(logical.InmemStorage)
+
+	b, err := Factory(ctx, &logical.BackendConfig{
+		StorageView: storage,
+		System:      logical.TestSystemView(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Initialize(ctx, &logical.InitializationRequest{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testBackend{t: t, backend: b, storage: storage}
+}
+
+// request handles a request the way Vault's router would, which sends writes to
+// paths that don't exist yet to their create operation.
+func (tb *testBackend) request(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+	ctx := context.Background()
+	req := &logical.Request{
+		Operation:  op,
+		Path:       path,
+		Data:       data,
+		Storage:    tb.storage,
+		MountPoint: "nats/",
+	}
+
+	if op == logical.UpdateOperation {
+		checkFound, exists, err := tb.backend.HandleExistenceCheck(ctx, req)
+		if err != nil {
+			return nil, err
+		} else if checkFound && !exists {
+			req.Operation = logical.CreateOperation
+		}
+	}
+
+	res, err := tb.backend.HandleRequest(ctx, req)
+	if err == nil && res != nil && res.IsError() {
+		err = res.Error()
+	}
+	return res, err
+}
+
+func (tb *testBackend) write(path string, data map[string]interface{}) *logical.Response {
+	tb.t.Helper()
+
+	res, err := tb.request(logical.UpdateOperation, path, data)
+	if err != nil {
+		tb.t.Fatalf("write %s: %v", path, err)
+	}
+	return res
+}
+
+func (tb *testBackend) read(path string) *logical.Response {
+	tb.t.Helper()
+
+	res, err := tb.request(logical.ReadOperation, path, nil)
+	if err != nil {
+		tb.t.Fatalf("read %s: %v", path, err)
+	}
+	return res
+}
+
+// secret handles a renewal or revocation of a lease
+func (tb *testBackend) secret(op logical.Operation, secret *logical.Secret) (*logical.Response, error) {
+	return tb.backend.HandleRequest(context.Background(), &logical.Request{
+		Operation:  op,
+		Secret:     secret,
+		Storage:    tb.storage,
+		MountPoint: "nats/",
+	})
+}
+
+// periodic runs the backend's periodic maintenance
+func (tb *testBackend) periodic() {
+	tb.t.Helper()
+
+	req := &logical.Request{Storage: tb.storage, MountPoint: "nats/"}
+	if err := tb.backend.(*framework.Backend).PeriodicFunc(context.Background(), req); err != nil {
+		tb.t.Fatal(err)
+	}
+}
+
+// edit changes a stored entry, i.e. to move timestamps into the past
+func (tb *testBackend) edit(key string, update func(entry map[string]interface{})) {
+	tb.t.Helper()
+
+	ctx := context.Background()
+	stored, err := tb.storage.Get(ctx, key)
+	if err != nil {
+		tb.t.Fatal(err)
+	} else if stored == nil {
+		tb.t.Fatalf("entry not found: %s", key)
+	}
+
+	entry := make(map[string]interface{})
+	if err := stored.DecodeJSON(&entry); err != nil {
+		tb.t.Fatal(err)
+	}
+	update(entry)
+
+	if stored, err = logical.StorageEntryJSON(key, entry); err != nil {
+		tb.t.Fatal(err)
+	} else if err := tb.storage.Put(ctx, stored); err != nil {
+		tb.t.Fatal(err)
+	}
+}
+
+func accountClaims(t *testing.T, res *logical.Response) *jwt.AccountClaims {
+	t.Helper()
+
+	claims, err := jwt.DecodeAccountClaims(res.Data["jwt"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestImportSubjectNarrowing(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("accounts/EXPORTER", nil)
+	tb.write("accounts/IMPORTER", nil)
+	tb.write("accounts/EXPORTER/exports/orders", map[string]interface{}{"subject": "orders.>", "type": "stream"})
+
+	whole := tb.write("accounts/IMPORTER/imports/orders", map[string]interface{}{"account": "EXPORTER", "export": "orders"})
+	if whole.Data["subject"] != "orders.>" {
+		t.Errorf("expected the export's subject to be imported, got %v", whole.Data["subject"])
+	}
+
+	narrowed := tb.write("accounts/IMPORTER/imports/orders-eu", map[string]interface{}{
+		"account": "EXPORTER",
+		"export":  "orders",
+		"subject": "orders.eu.>",
+	})
+	if narrowed.Data["subject"] != "orders.eu.>" {
+		t.Errorf("expected the narrowed subject to be imported, got %v", narrowed.Data["subject"])
+	}
+
+	if _, err := tb.request(logical.UpdateOperation, "accounts/IMPORTER/imports/payments", map[string]interface{}{
+		"account": "EXPORTER",
+		"export":  "orders",
+		"subject": "payments.>",
+	}); err == nil {
+		t.Error("expected an error importing a subject outside of the export")
+	}
+
+	// Re-resolving imports, i.e. when the exporter is rotated, keeps the narrowed subject
+	tb.write("accounts/EXPORTER/rotate", nil)
+
+	claims := accountClaims(t, tb.read("accounts/IMPORTER"))
+	subjects := make(map[string]bool)
+	for _, imp := range claims.Imports {
+		subjects[string(imp.Subject)] = true
+	}
+	if !subjects["orders.>"] || !subjects["orders.eu.>"] || len(subjects) != 2 {
+		t.Errorf("expected imports of orders.> and orders.eu.>, got %v", subjects)
+	}
+}
+
+func TestExportChangesUpdateImporters(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("accounts/EXPORTER", nil)
+	tb.write("accounts/IMPORTER", nil)
+	tb.write("accounts/EXPORTER/exports/orders", map[string]interface{}{"subject": "orders.>", "type": "stream"})
+	tb.write("accounts/IMPORTER/imports/orders", map[string]interface{}{"account": "EXPORTER", "export": "orders"})
+	tb.write("accounts/IMPORTER/imports/orders-eu", map[string]interface{}{
+		"account": "EXPORTER",
+		"export":  "orders",
+		"subject": "orders.eu.>",
+	})
+
+	// Private exports issue activations to the importers
+	private := tb.write("accounts/EXPORTER/exports/orders", map[string]interface{}{"token_req": true})
+	if importers := private.Data["importers"].([]string); len(importers) != 1 || importers[0] != "IMPORTER" {
+		t.Errorf("expected IMPORTER to be updated, got %v", importers)
+	}
+	for _, imp := range accountClaims(t, tb.read("accounts/IMPORTER")).Imports {
+		if imp.Token == "" {
+			t.Errorf("expected an activation token for the import of %s", imp.Subject)
+		}
+	}
+
+	// Imports of the whole export follow its subject, and imports of subjects
+	// the export no longer contains are removed
+	tb.write("accounts/EXPORTER/exports/orders", map[string]interface{}{"subject": "orders.us.>"})
+	claims := accountClaims(t, tb.read("accounts/IMPORTER"))
+	if len(claims.Imports) != 1 || claims.Imports[0].Subject != "orders.us.>" {
+		t.Errorf("expected only the import of orders.us.> to remain, got %d imports", len(claims.Imports))
+	}
+
+	deleted, err := tb.request(logical.DeleteOperation, "accounts/EXPORTER/exports/orders", nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if importers := deleted.Data["importers"].([]string); len(importers) != 1 {
+		t.Errorf("expected IMPORTER to be updated, got %v", importers)
+	}
+
+	if claims := accountClaims(t, tb.read("accounts/IMPORTER")); len(claims.Imports) != 0 {
+		t.Errorf("expected the imports of the deleted export to be removed, got %v", claims.Imports)
+	}
+	if res, err := tb.request(logical.ListOperation, "accounts/IMPORTER/imports", nil); err != nil {
+		t.Fatal(err)
+	} else if len(res.Data) != 0 {
+		t.Errorf("expected no imports, got %v", res.Data["keys"])
+	}
+}
+
+func TestRevokedActivationsReinstate(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("accounts/EXPORTER", nil)
+	tb.write("accounts/IMPORTER", nil)
+	tb.write("accounts/EXPORTER/exports/orders", map[string]interface{}{"subject": "orders.>", "type": "stream", "token_req": true})
+	tb.write("accounts/IMPORTER/imports/orders", map[string]interface{}{"account": "EXPORTER", "export": "orders"})
+
+	if _, err := tb.request(logical.DeleteOperation, "accounts/EXPORTER/exports/orders/activations/IMPORTER", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revoked targets can't be issued activations or import the export
+	if _, err := tb.request(logical.UpdateOperation, "accounts/EXPORTER/exports/orders/activations/IMPORTER", nil); err == nil {
+		t.Error("expected an error issuing an activation to a revoked target")
+	}
+	if _, err := tb.request(logical.UpdateOperation, "accounts/IMPORTER/imports/orders", map[string]interface{}{"account": "EXPORTER", "export": "orders"}); err == nil {
+		t.Error("expected an error importing an export whose activation was revoked")
+	}
+
+	tb.write("accounts/EXPORTER/exports/orders/activations/IMPORTER", map[string]interface{}{"reinstate": true})
+	if claims := accountClaims(t, tb.read("accounts/EXPORTER")); len(claims.Exports[0].Revocations) != 0 {
+		t.Errorf("expected the revocation to be removed, got %v", claims.Exports[0].Revocations)
+	}
+
+	tb.write("accounts/IMPORTER/imports/orders", map[string]interface{}{"account": "EXPORTER", "export": "orders"})
+}
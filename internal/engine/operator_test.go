@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/operator"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+func TestStrictSigningKeysReissueAccounts(t *testing.T) {
+	tb := newTestBackend(t)
+
+	opPubKey := tb.read("operator").Data["public_key"].(string)
+	if issuer := accountClaims(t, tb.write("accounts/APP", nil)).Issuer; issuer != opPubKey {
+		t.Fatalf("expected the account to be issued by the operator %s, got %s", opPubKey, issuer)
+	}
+	tb.write("accounts/OTHER", nil)
+
+	signingKey := tb.write("operator/signing-keys/accounts", nil).Data["public_key"].(string)
+	if issuer := accountClaims(t, tb.write("accounts/APP", map[string]interface{}{"operator_signing_key": "accounts"})).Issuer; issuer != signingKey {
+		t.Fatalf("expected the account to be issued by the signing key %s, got %s", signingKey, issuer)
+	}
+
+	// Accounts that would be issued by the identity key can't be signed in strict mode
+	strict := tb.write("operator", map[string]interface{}{"strict_signing_keys": true})
+	accounts := strict.Data["accounts"].(map[string]operator.ReissuedAccount)
+	if accounts["OTHER"].Error == "" {
+		t.Error("expected an error re-issuing an account without an operator signing key")
+	} else if accounts["APP"].Error != "" {
+		t.Errorf("unexpected error re-issuing an account with an operator signing key: %s", accounts["APP"].Error)
+	}
+
+	if _, err := tb.request(logical.ReadOperation, "accounts/OTHER", nil); err == nil {
+		t.Error("expected an error reading an account that can't be issued in strict mode")
+	}
+
+	// Replacing the signing key re-signs the accounts it issued
+	nk, err := nkeys.CreateOperator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, err := nk.Seed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replaced := tb.write("operator/signing-keys/accounts", map[string]interface{}{"nkey": string(seed)})
+	signingKey = replaced.Data["public_key"].(string)
+
+	stored, err := tb.storage.Get(context.Background(), "accounts/APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var account struct {
+		Jwt string `json:"jwt"`
+	}
+	if err := stored.DecodeJSON(&account); err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := jwt.DecodeAccountClaims(account.Jwt)
+	if err != nil {
+		t.Fatal(err)
+	} else if claims.Issuer != signingKey {
+		t.Errorf("expected the stored account JWT to be issued by %s, got %s", signingKey, claims.Issuer)
+	}
+}
+
+func TestOperatorJwtIsStored(t *testing.T) {
+	tb := newTestBackend(t)
+
+	first := tb.read("operator")
+	second := tb.read("operator")
+	if first.Data["jwt"] != second.Data["jwt"] || first.Data["jwt_hash"] != second.Data["jwt_hash"] {
+		t.Error("expected reads of the operator to return the same JWT")
+	}
+
+	stored, err := tb.storage.Get(context.Background(), "operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var op struct {
+		Jwt string `json:"jwt"`
+	}
+	if err := stored.DecodeJSON(&op); err != nil {
+		t.Fatal(err)
+	} else if op.Jwt != first.Data["jwt"] {
+		t.Error("expected reads of the operator to return the stored JWT")
+	}
+
+	config := tb.read("operator/server-config")
+	if !strings.Contains(config.Data["config"].(string), first.Data["jwt"].(string)) {
+		t.Error("expected the server config to trust the stored operator JWT")
+	}
+
+	renamed := tb.write("operator", map[string]interface{}{"name": "acme"})
+	if claims, err := jwt.DecodeOperatorClaims(renamed.Data["jwt"].(string)); err != nil {
+		t.Fatal(err)
+	} else if claims.Name != "acme" {
+		t.Errorf("expected the operator to be renamed to acme, got %s", claims.Name)
+	}
+}
+
+func TestSigningKeyInUseCannotBeDeleted(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("operator/signing-keys/accounts", nil)
+	tb.write("accounts/APP", map[string]interface{}{"operator_signing_key": "accounts"})
+
+	if _, err := tb.request(logical.DeleteOperation, "operator/signing-keys/accounts", nil); err == nil || !strings.Contains(err.Error(), "APP") {
+		t.Errorf("expected an error deleting a signing key that issues APP, got: %v", err)
+	}
+
+	tb.write("accounts/APP", map[string]interface{}{"operator_signing_key": ""})
+	if _, err := tb.request(logical.DeleteOperation, "operator/signing-keys/accounts", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportedOperatorKeepsClaims(t *testing.T) {
+	tb := newTestBackend(t)
+
+	nk, err := nkeys.CreateOperator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, err := nk.Seed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := nk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.NewOperatorClaims(pubKey)
+	claims.Name = "acme"
+	claims.Audience = "acme-servers"
+	claims.NotBefore = time.Now().Add(-time.Hour).Unix()
+	claims.Expires = time.Now().Add(720 * time.Hour).Unix()
+	token, err := claims.Encode(nk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := tb.write("operator/import", map[string]interface{}{"jwt": token, "nkey": string(seed)})
+	if imported.Data["jwt"] != token {
+		t.Error("expected the imported operator JWT to be kept")
+	} else if imported.Data["jwt_ttl"] != 720*60*60 {
+		t.Errorf("expected the JWT's lifetime to be kept as its TTL, got %v", imported.Data["jwt_ttl"])
+	}
+
+	// Re-signing the JWT keeps the claims that aren't managed by the mount
+	updated := tb.write("operator", map[string]interface{}{"tags": "prod"})
+	reissued, err := jwt.DecodeOperatorClaims(updated.Data["jwt"].(string))
+	if err != nil {
+		t.Fatal(err)
+	} else if reissued.Expires == 0 || reissued.Audience != claims.Audience || reissued.NotBefore != claims.NotBefore {
+		t.Errorf("expected the expiry, audience and not before claims to be kept, got %d, %s and %d", reissued.Expires, reissued.Audience, reissued.NotBefore)
+	}
+}
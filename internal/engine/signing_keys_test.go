@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+)
+
+func TestSigningKeyRotationGrace(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("accounts/APP", nil)
+	tb.write("accounts/APP/signing-keys/readonly", map[string]interface{}{"role": "readonly", "sub_allow": "orders.>"})
+
+	first := tb.write("accounts/APP/signing-keys/readonly/rotate", nil)
+	second := tb.write("accounts/APP/signing-keys/readonly/rotate", nil)
+
+	firstRetired := first.Data["retired_signing_key"].(string)
+	secondRetired := second.Data["retired_signing_key"].(string)
+	if firstRetired == secondRetired {
+		t.Fatalf("rotations in the same second retired both keys as %s", firstRetired)
+	}
+
+	// Both retired keys are trusted until the users they issued expire
+	claims := accountClaims(t, tb.read("accounts/APP"))
+	for _, key := range []string{first.Data["previous_public_key"].(string), second.Data["previous_public_key"].(string), second.Data["public_key"].(string)} {
+		if _, ok := claims.SigningKeys[key]; !ok {
+			t.Errorf("expected the account JWT to include signing key %s", key)
+		}
+	}
+
+	if _, err := tb.request(logical.ReadOperation, "accounts/APP/user-creds", map[string]interface{}{"signing_key": secondRetired}); err == nil {
+		t.Error("expected an error issuing credentials with a retired signing key")
+	}
+
+	creds, err := tb.request(logical.ReadOperation, "accounts/APP/user-creds", map[string]interface{}{"signing_key": "readonly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := jwt.DecodeUserClaims(creds.Data["jwt"].(string))
+	if err != nil {
+		t.Fatal(err)
+	} else if user.Issuer != second.Data["public_key"] {
+		t.Errorf("expected the user to be issued by %s, got %s", second.Data["public_key"], user.Issuer)
+	}
+
+	tb.edit("accounts/APP", func(entry map[string]interface{}) {
+		entry["signing_key_expirations"].(map[string]interface{})[firstRetired] = time.Now().Add(-time.Minute).Unix()
+	})
+	tb.periodic()
+
+	claims = accountClaims(t, tb.read("accounts/APP"))
+	if _, ok := claims.SigningKeys[first.Data["previous_public_key"].(string)]; ok {
+		t.Error("expected the expired signing key to be removed from the account JWT")
+	}
+	if _, ok := claims.SigningKeys[second.Data["previous_public_key"].(string)]; !ok {
+		t.Error("expected the signing key in its grace period to remain in the account JWT")
+	}
+}
+
+func TestRenewalRequiresRole(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("accounts/APP", nil)
+	tb.write("accounts/APP/signing-keys/readonly", map[string]interface{}{"role": "readonly", "sub_allow": "orders.>"})
+	tb.write("accounts/APP/signing-keys/audit", map[string]interface{}{"role": "audit", "sub_allow": "audit.>"})
+
+	readonly, err := tb.request(logical.ReadOperation, "accounts/APP/user-creds", map[string]interface{}{"signing_key": "readonly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err := tb.request(logical.ReadOperation, "accounts/APP/user-creds", map[string]interface{}{"signing_key": "audit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tb.secret(logical.RenewOperation, readonly.Secret); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renewals can't fall back to the user signing key, which has no permissions
+	if _, err := tb.request(logical.DeleteOperation, "accounts/APP/signing-keys/readonly", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tb.secret(logical.RenewOperation, readonly.Secret); err == nil {
+		t.Error("expected an error renewing credentials of a deleted signing key")
+	}
+
+	tb.write("accounts/APP/signing-keys/audit", map[string]interface{}{"scoped": false})
+	if _, err := tb.secret(logical.RenewOperation, audit.Secret); err == nil {
+		t.Error("expected an error renewing credentials of a signing key that is no longer scoped")
+	}
+}
+
+func TestLegacyAccountMigrationWarns(t *testing.T) {
+	tb := newTestBackend(t)
+
+	tb.write("accounts/APP", nil)
+	tb.edit("accounts/APP", func(entry map[string]interface{}) {
+		delete(entry, "signing_keys")
+		delete(entry, "user_signing_key")
+	})
+
+	creds := tb.read("accounts/APP/user-creds")
+	if len(creds.Warnings) != 1 {
+		t.Fatalf("expected a warning that the account JWT was re-signed, got %v", creds.Warnings)
+	}
+
+	// The account has been migrated, so later credentials don't warn
+	if again := tb.read("accounts/APP/user-creds"); len(again.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", again.Warnings)
+	}
+
+	claims := accountClaims(t, tb.read("accounts/APP"))
+	user, err := jwt.DecodeUserClaims(creds.Data["jwt"].(string))
+	if err != nil {
+		t.Fatal(err)
+	} else if _, ok := claims.SigningKeys[user.Issuer]; !ok {
+		t.Errorf("expected the user to be issued by one of the account's signing keys, got %s", user.Issuer)
+	}
+}
@@ -28,6 +28,7 @@ func NewBackend() (logical.Backend, error) {
 		operator.ProviderSet,
 		NewPaths,
 		NewSecrets,
+		wire.Bind(new(operator.AccountIssuer), new(*account.Service)),
 		wire.Bind(new(logical.Backend), new(*framework.Backend)),
 	))
 }
@@ -16,22 +16,25 @@ import (
 // Injectors from wire.go:
 
 func NewBackend() (logical.Backend, error) {
-	service := &operator.Service{}
 	logger := NewLogger()
 	userCredsService := &account.UserCredsService{
 		Logger: logger,
 	}
-	paths := operator.NewPaths(service)
 	userCredentialsSecret := account.NewUserCredentialsSecret(userCredsService)
-	accountService := &account.Service{
+	service := &account.Service{
 		Secret: userCredentialsSecret,
 		Logger: logger,
 	}
-	accountPaths := account.NewPaths(accountService)
+	operatorService := &operator.Service{
+		Log:      logger,
+		Accounts: service,
+	}
+	paths := operator.NewPaths(operatorService)
+	accountPaths := account.NewPaths(service)
 	v := NewPaths(paths, accountPaths)
 	v2 := NewSecrets(userCredentialsSecret)
-	frameworkBackend := NewNatsEngine(service, userCredsService, v, v2)
-	return frameworkBackend, nil
+	backend := NewNatsEngine(operatorService, service, userCredsService, v, v2)
+	return backend, nil
 }
 
 // wire.go:
@@ -0,0 +1,129 @@
+package jwtutil
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Validate runs the claims' validation, and returns an error describing
+// any issues that would prevent a NATS server from accepting them.
+func Validate(claims jwt.Claims) error {
+	vr := jwt.CreateValidationResults()
+	claims.Validate(vr)
+
+	if !vr.IsBlocking(false) {
+		return nil
+	}
+
+	errs := vr.Errors()
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Errorf("invalid claims: %s", strings.Join(msgs, "; "))
+}
+
+// Issue signs the claims, unless the previously issued JWT already contains
+// the same claims. This keeps the JWT's iat and jti stable until something
+// about it actually changes. If a TTL is given, the JWT will expire, and is
+// re-signed once a third of the TTL remains.
+func Issue(claims jwt.Claims, kp nkeys.KeyPair, previous string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if ttl > 0 {
+		claims.Claims().Expires = now.Add(ttl).Unix()
+	}
+
+	token, err := claims.Encode(kp)
+	if err != nil {
+		return "", err
+	}
+
+	if previous != "" && Equivalent(previous, token) && !expiring(previous, ttl, now) {
+		return previous, nil
+	}
+
+	return token, nil
+}
+
+// expiring reports whether the JWT's expiry no longer matches the TTL it should
+// be issued with, or it is close enough to expiring that it should be re-signed.
+func expiring(token string, ttl time.Duration, now time.Time) bool {
+	claims, err := payload(token)
+	if err != nil {
+		return true
+	}
+
+	exp, _ := claims["exp"].(float64)
+	if ttl <= 0 {
+		return exp != 0
+	}
+
+	remaining := time.Unix(int64(exp), 0).Sub(now)
+	return exp == 0 || remaining < ttl/3 || remaining > ttl
+}
+
+// Equivalent reports whether two JWTs contain the same claims, ignoring
+// the fields that change every time a JWT is signed.
+func Equivalent(a, b string) bool {
+	ac, err := payload(a)
+	if err != nil {
+		return false
+	}
+
+	bc, err := payload(b)
+	if err != nil {
+		return false
+	}
+
+	for _, field := range []string{"iat", "jti", "exp"} {
+		delete(ac, field)
+		delete(bc, field)
+	}
+
+	return reflect.DeepEqual(ac, bc)
+}
+
+// Hash returns a digest of the JWT, which can be used to detect changes
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func payload(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("expected 3 chunks in jwt, got %d", len(parts))
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return nil, err
+	}
+
+	// Account signing keys are serialized from a map, so their order isn't stable
+	if nats, ok := claims["nats"].(map[string]interface{}); ok {
+		if keys, ok := nats["signing_keys"].([]interface{}); ok {
+			sort.Slice(keys, func(i, j int) bool {
+				return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+			})
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,107 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Import replaces the operator with an existing one (i.e. created using nsc).
+// The operator JWT's claims are persisted, so JWTs issued by the mount will
+// continue to include them.
+func (os *Service) Import(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	opName := operatorName(fd)
+
+	token := fd.Get("jwt").(string)
+	if token == "" {
+		return nil, errors.New("operator jwt cannot be empty")
+	}
+
+	seed := fd.Get("nkey").(string)
+	if seed == "" {
+		return nil, errors.New("operator nkey cannot be empty")
+	}
+
+	claims, err := jwt.DecodeOperatorClaims(token)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding operator jwt: %w", err)
+	}
+
+	nk, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return nil, err
+	}
+
+	pubkey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	} else if !nkeys.IsValidPublicOperatorKey(pubkey) {
+		return nil, errors.New("nkey must be an operator nkey")
+	} else if claims.Subject != pubkey {
+		return nil, fmt.Errorf("operator jwt subject %s does not match nkey %s", claims.Subject, pubkey)
+	} else if claims.Issuer != pubkey {
+		return nil, fmt.Errorf("operator jwt must be self-signed, but was issued by %s", claims.Issuer)
+	}
+
+	op, err := GetOperator(ctx, req.Storage, opName)
+	if err != nil {
+		return nil, err
+	} else if op == nil {
+		op = new(Operator)
+	}
+
+	op.Nkey = seed
+	op.Jwt = token
+	op.Name = claims.Name
+	op.StrictSigningKeys = claims.StrictSigningKeyUsage
+	op.OperatorServiceUrls = claims.OperatorServiceURLs
+	op.AccountServerUrl = claims.AccountServerURL
+	op.SystemAccount = claims.SystemAccount
+	op.AssertServerVersion = claims.AssertServerVersion
+	op.Tags = claims.Tags
+	op.Audience = claims.Audience
+	op.NotBefore = claims.NotBefore
+
+	// The JWT is re-signed with the same lifetime when it expires
+	op.JwtTtl = 0
+	if claims.Expires > 0 && claims.IssuedAt > 0 && claims.Expires > claims.IssuedAt {
+		op.JwtTtl = int(claims.Expires - claims.IssuedAt)
+	}
+
+	// Signing keys are only included in the JWT as public keys. Any that aren't
+	// already managed by the mount are retained, but can't be used for signing.
+	managed := make(map[string]bool, len(op.SigningKeys))
+	for _, sk := range op.SigningKeys {
+		if kp, err := nkeys.FromSeed([]byte(sk)); err != nil {
+			return nil, err
+		} else if skPub, err := kp.PublicKey(); err != nil {
+			return nil, err
+		} else {
+			managed[skPub] = true
+		}
+	}
+
+	op.ExternalSigningKeys = nil
+	for _, sk := range claims.SigningKeys {
+		if !managed[sk] {
+			op.ExternalSigningKeys = append(op.ExternalSigningKeys, sk)
+		}
+	}
+
+	if err := putOperator(ctx, req.Storage, opName, op); err != nil {
+		return nil, err
+	}
+
+	res, err := operatorResponse(op)
+	if err != nil {
+		return nil, err
+	}
+
+	// Existing accounts need to be signed by the imported operator
+	return os.withReissuedAccounts(ctx, req.Storage, opName, res)
+}
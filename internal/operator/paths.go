@@ -2,8 +2,12 @@ package operator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/jwtutil"
 	"github.com/egoodhall/vault-secrets-engine-nats/internal/nkutil"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/framework"
@@ -15,31 +19,216 @@ import (
 type Paths []*framework.Path
 
 func NewPaths(os *Service) Paths {
+	named := operatorPaths(os, "operators/"+framework.GenericNameRegex("operator"), map[string]*framework.FieldSchema{
+		"operator": {
+			Type:        framework.TypeString,
+			Description: "The operator name",
+			Required:    true,
+		},
+	})
+	named[0].Operations[logical.DeleteOperation] = &framework.PathOperation{Callback: os.Delete}
+
+	return framework.PathAppend(
+		[]*framework.Path{
+			{
+				Pattern: "operators/?$",
+				Operations: map[logical.Operation]framework.OperationHandler{
+					logical.ListOperation: &framework.PathOperation{Callback: os.List},
+				},
+			},
+		},
+		operatorPaths(os, "operator", nil),
+		named,
+	)
+}
+
+// operatorPaths builds the paths used to manage an operator. They are registered
+// for both the default operator, and for named operators.
+func operatorPaths(os *Service, prefix string, fields map[string]*framework.FieldSchema) []*framework.Path {
 	return []*framework.Path{
 		{
-			Pattern: "operator",
-			Fields: map[string]*framework.FieldSchema{
+			Pattern: prefix,
+			Fields: withFields(fields, map[string]*framework.FieldSchema{
 				"nkey": {
 					Type:        framework.TypeString,
-					Description: "The NKey that will be used as the root of the trust chain",
+					Description: "The NKey that will be used as the root of the trust chain. It can only be set when the operator is created",
 					Required:    false,
 				},
-			},
+				"name": {
+					Type:        framework.TypeString,
+					Description: "The name of the operator in its JWT. Named operators default to their name, and the default operator to \"default\"",
+					Required:    false,
+				},
+				"strict_signing_keys": {
+					Type:        framework.TypeBool,
+					Description: "Require account JWTs to be issued by an operator signing key, rather than the operator's identity key",
+					Required:    false,
+				},
+				"operator_service_urls": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "NATS URLs (nats:// or tls://) that tools can use to connect to the operator's servers",
+					Required:    false,
+				},
+				"account_server_url": {
+					Type:        framework.TypeString,
+					Description: "The URL of an account server that tools can use to look up account JWTs",
+					Required:    false,
+				},
+				"system_account": {
+					Type:        framework.TypeString,
+					Description: "The public key of the system account",
+					Required:    false,
+				},
+				"assert_server_version": {
+					Type:        framework.TypeString,
+					Description: "The minimum nats-server version (<major>.<minor>.<update>) that may use the operator JWT",
+					Required:    false,
+				},
+				"tags": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Tags to include in the operator JWT",
+					Required:    false,
+				},
+				"jwt_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long the operator JWT is valid for. It is re-signed before it expires. The JWT doesn't expire if not set",
+					Required:    false,
+				},
+			}),
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{Callback: os.Write},
 				logical.UpdateOperation: &framework.PathOperation{Callback: os.Write},
 				logical.ReadOperation:   &framework.PathOperation{Callback: os.Read},
 			},
 		},
+		{
+			Pattern: prefix + "/signing-keys/?$",
+			Fields:  withFields(fields, nil),
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{Callback: os.ListSigningKeys},
+			},
+		},
+		{
+			Pattern: prefix + "/signing-keys/" + framework.GenericNameRegex("name"),
+			Fields: withFields(fields, map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "The signing key name",
+					Required:    true,
+				},
+				"nkey": {
+					Type:        framework.TypeString,
+					Description: "The NKey seed to use as the signing key. One will be generated if not provided",
+					Required:    false,
+				},
+			}),
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{Callback: os.WriteSigningKey},
+				logical.UpdateOperation: &framework.PathOperation{Callback: os.WriteSigningKey},
+				logical.ReadOperation:   &framework.PathOperation{Callback: os.ReadSigningKey},
+				logical.DeleteOperation: &framework.PathOperation{Callback: os.DeleteSigningKey},
+			},
+		},
+		{
+			Pattern: prefix + "/import",
+			Fields: withFields(fields, map[string]*framework.FieldSchema{
+				"jwt": {
+					Type:        framework.TypeString,
+					Description: "The existing operator JWT",
+					Required:    true,
+				},
+				"nkey": {
+					Type:        framework.TypeString,
+					Description: "The NKey seed the existing operator JWT was signed with",
+					Required:    true,
+				},
+			}),
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{Callback: os.Import},
+			},
+		},
+		{
+			Pattern: prefix + "/server-config",
+			Fields: withFields(fields, map[string]*framework.FieldSchema{
+				"resolver": {
+					Type:        framework.TypeString,
+					Description: "The account resolver to configure: memory, full or url",
+					Default:     resolverMemory,
+					Required:    false,
+				},
+				"resolver_dir": {
+					Type:        framework.TypeString,
+					Description: "The directory the full resolver stores account JWTs in",
+					Default:     "./jwt",
+					Required:    false,
+				},
+				"allow_delete": {
+					Type:        framework.TypeBool,
+					Description: "Whether the full resolver allows accounts to be deleted",
+					Default:     false,
+					Required:    false,
+				},
+				"interval": {
+					Type:        framework.TypeString,
+					Description: "How often the full resolver synchronizes with other servers",
+					Default:     "2m",
+					Required:    false,
+				},
+				"resolver_url": {
+					Type:        framework.TypeString,
+					Description: "The URL used by the url resolver. Defaults to the operator's account server URL",
+					Required:    false,
+				},
+				"format": {
+					Type:        framework.TypeString,
+					Description: "The format of the generated configuration: conf or json",
+					Default:     "conf",
+					Required:    false,
+				},
+			}),
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{Callback: os.ServerConfig},
+			},
+		},
+		{
+			Pattern: prefix + "/rotate",
+			Fields: withFields(fields, map[string]*framework.FieldSchema{
+				"nkey": {
+					Type:        framework.TypeString,
+					Description: "The NKey seed to use as the new identity key. One will be generated if not provided",
+					Required:    false,
+				},
+				"grace_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long to keep the previous identity key as a signing key. It is discarded immediately if not set",
+					Required:    false,
+				},
+			}),
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{Callback: os.Rotate},
+			},
+		},
+	}
+}
+
+func withFields(fields, pathFields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	merged := make(map[string]*framework.FieldSchema, len(fields)+len(pathFields))
+	for k, v := range fields {
+		merged[k] = v
+	}
+	for k, v := range pathFields {
+		merged[k] = v
 	}
+	return merged
 }
 
 type Service struct {
-	Log hclog.Logger
+	Log      hclog.Logger
+	Accounts AccountIssuer
 }
 
 func (os *Service) InitOperator(ctx context.Context, req *logical.InitializationRequest) error {
-	op, err := GetOperator(ctx, req.Storage)
+	op, err := GetOperator(ctx, req.Storage, "")
 	if err != nil {
 		return err
 	} else if op == nil {
@@ -54,89 +243,205 @@ func (os *Service) InitOperator(ctx context.Context, req *logical.Initialization
 		op.Nkey = string(seed)
 	}
 
-	if e, err := logical.StorageEntryJSON(storagePath, op); err != nil {
-		return err
-	} else if err := req.Storage.Put(ctx, e); err != nil {
-		return err
+	return putOperator(ctx, req.Storage, "", op)
+}
+
+func (os *Service) List(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List(ctx, "operators/")
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return logical.ListResponse(names), nil
 }
 
 func (os *Service) Write(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
-	op, err := GetOperator(ctx, req.Storage)
+	opName := operatorName(fd)
+
+	op, err := GetOperator(ctx, req.Storage, opName)
 	if err != nil {
 		return nil, err
 	} else if op == nil {
 		op = new(Operator)
 	}
 
-	nk, err := nkutil.GetOrDefault(fd, "nkey", func() (nkeys.KeyPair, error) {
-		return nkeys.FromSeed([]byte(op.Nkey))
-	})
-	if err != nil {
-		return nil, err
-	} else if pk, err := nk.Seed(); err == nil {
-		op.Nkey = string(pk)
+	// Replacing the identity key invalidates every JWT the operator has issued,
+	// so it's only done by rotating the operator, which re-signs its accounts
+	if op.Nkey == "" {
+		nk, err := nkutil.GetOrDefault(fd, "nkey", nkeys.CreateOperator)
+		if err != nil {
+			return nil, err
+		} else if pk, err := nk.Seed(); err == nil {
+			op.Nkey = string(pk)
+		}
+	} else if seed, ok := fd.GetOk("nkey"); ok && seed.(string) != op.Nkey {
+		return nil, fmt.Errorf("operator nkey can only be replaced by rotating the operator (%s/rotate)", storagePath(opName))
 	}
 
-	if e, err := logical.StorageEntryJSON(storagePath, op); err != nil {
-		return nil, err
-	} else if err := req.Storage.Put(ctx, e); err != nil {
+	if name, ok := fd.GetOk("name"); ok {
+		op.Name = name.(string)
+	}
+
+	if strict, ok := fd.GetOk("strict_signing_keys"); ok {
+		op.StrictSigningKeys = strict.(bool)
+	}
+
+	if op.StrictSigningKeys && len(op.SigningKeys) == 0 {
+		return nil, errors.New("strict signing key usage requires at least one operator signing key")
+	}
+
+	if urls, ok := fd.GetOk("operator_service_urls"); ok {
+		op.OperatorServiceUrls = urls.([]string)
+	}
+
+	if url, ok := fd.GetOk("account_server_url"); ok {
+		op.AccountServerUrl = url.(string)
+	}
+
+	if sysAccount, ok := fd.GetOk("system_account"); ok {
+		op.SystemAccount = sysAccount.(string)
+	}
+
+	if version, ok := fd.GetOk("assert_server_version"); ok {
+		op.AssertServerVersion = version.(string)
+	}
+
+	if tags, ok := fd.GetOk("tags"); ok {
+		op.Tags = tags.([]string)
+	}
+
+	if ttl, ok := fd.GetOk("jwt_ttl"); ok {
+		op.JwtTtl = ttl.(int)
+	}
+
+	if err := putOperator(ctx, req.Storage, opName, op); err != nil {
 		return nil, err
 	}
 
-	pubkey, opJwt, err := genJwt(req.MountPoint, nk)
+	res, err := operatorResponse(op)
 	if err != nil {
 		return nil, err
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"public_key": pubkey,
-			"jwt":        opJwt,
-		},
-	}, nil
+	return os.withReissuedAccounts(ctx, req.Storage, opName, res)
 }
 
 func (cs *Service) Read(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
-	op, err := GetOperator(ctx, req.Storage)
+	opName := operatorName(fd)
+
+	op, err := GetOperator(ctx, req.Storage, opName)
 	if err != nil {
 		return nil, err
+	} else if op == nil {
+		return nil, nil
+	}
+
+	return operatorResponse(op)
+}
+
+// Delete removes a named operator. The default operator can't be deleted, and
+// named operators can only be deleted once no accounts are bound to them.
+func (os *Service) Delete(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	opName := operatorName(fd)
+	if opName == "" {
+		return nil, errors.New("the default operator cannot be deleted")
 	}
 
-	pubkey, opJwt, err := genFromSeed(req.MountPoint, op.Nkey)
+	accounts, err := os.Accounts.AccountNames(ctx, req.Storage, opName)
 	if err != nil {
 		return nil, err
+	} else if len(accounts) > 0 {
+		return nil, fmt.Errorf("operator %s still has accounts: %s", opName, strings.Join(accounts, ", "))
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"public_key": pubkey,
-			"jwt":        opJwt,
-		},
-	}, nil
+	if err := req.Storage.Delete(ctx, storagePath(opName)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
 }
 
-func genFromSeed(mount, seed string) (pubkey, opJwt string, err error) {
-	opNkey, err := nkeys.FromSeed([]byte(seed))
-	if err != nil {
-		return "", "", err
+// operatorName returns the name of the operator a request refers to. The default
+// operator's paths don't include a name, so they use an empty one.
+func operatorName(fd *framework.FieldData) string {
+	if name, ok := fd.GetOk("operator"); ok {
+		return name.(string)
 	}
-
-	return genJwt(mount, opNkey)
+	return ""
 }
 
-func genJwt(mount string, nkey nkeys.KeyPair) (pubkey, opJwt string, err error) {
-	pubkey, err = nkey.PublicKey()
+// issueJwt signs the operator's claims, and stores the JWT on the operator. The
+// previously issued JWT is kept if the claims haven't changed.
+func issueJwt(op *Operator) error {
+	nkey, err := nkeys.FromSeed([]byte(op.Nkey))
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := nkey.PublicKey()
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
 	claims := new(jwt.OperatorClaims)
 	claims.Subject = pubkey
-	claims.Name = strings.TrimRight(mount, "/")
+	claims.Name = op.Name
+	claims.Audience = op.Audience
+	claims.NotBefore = op.NotBefore
+	claims.StrictSigningKeyUsage = op.StrictSigningKeys
+	claims.OperatorServiceURLs.Add(op.OperatorServiceUrls...)
+	claims.AccountServerURL = op.AccountServerUrl
+	claims.SystemAccount = op.SystemAccount
+	claims.AssertServerVersion = op.AssertServerVersion
+	claims.Tags.Add(op.Tags...)
+
+	for _, name := range sortedKeys(op.SigningKeys) {
+		sk, err := nkeys.FromSeed([]byte(op.SigningKeys[name]))
+		if err != nil {
+			return err
+		}
 
-	opJwt, err = claims.Encode(nkey)
-	return
+		skPub, err := sk.PublicKey()
+		if err != nil {
+			return err
+		}
+		claims.SigningKeys.Add(skPub)
+	}
+	claims.SigningKeys.Add(op.ExternalSigningKeys...)
+
+	if err := jwtutil.Validate(claims); err != nil {
+		return err
+	}
+
+	opJwt, err := jwtutil.Issue(claims, nkey, op.Jwt, time.Duration(op.JwtTtl)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	op.Jwt = opJwt
+	return nil
+}
+
+func operatorResponse(op *Operator) (*logical.Response, error) {
+	pubkey, err := op.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":                  op.Name,
+			"public_key":            pubkey,
+			"jwt":                   op.Jwt,
+			"jwt_hash":              jwtutil.Hash(op.Jwt),
+			"external_signing_keys": op.ExternalSigningKeys,
+			"strict_signing_keys":   op.StrictSigningKeys,
+			"operator_service_urls": op.OperatorServiceUrls,
+			"account_server_url":    op.AccountServerUrl,
+			"system_account":        op.SystemAccount,
+			"assert_server_version": op.AssertServerVersion,
+			"tags":                  op.Tags,
+			"jwt_ttl":               op.JwtTtl,
+		},
+	}, nil
 }
@@ -0,0 +1,196 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/nkutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/nkeys"
+)
+
+// ReissuedAccount describes the outcome of re-signing an account's JWT
+type ReissuedAccount struct {
+	PublicKey string `json:"public_key,omitempty"`
+	Jwt       string `json:"jwt,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// AccountIssuer manages the accounts issued by an operator
+type AccountIssuer interface {
+	// AccountNames lists the accounts bound to the named operator
+	AccountNames(ctx context.Context, s logical.Storage, operator string) ([]string, error)
+	// SigningKeyAccounts lists the accounts bound to the named operator that are issued by the signing key
+	SigningKeyAccounts(ctx context.Context, s logical.Storage, operator, signingKey string) ([]string, error)
+	// ReissueAccounts re-signs the JWTs of the accounts bound to the named operator
+	ReissueAccounts(ctx context.Context, s logical.Storage, operator string) (map[string]ReissuedAccount, error)
+	// AccountJwts returns the JWTs of the accounts bound to the named operator, keyed by public key
+	AccountJwts(ctx context.Context, s logical.Storage, operator string) (map[string]string, error)
+}
+
+// Rotate replaces the operator's identity key, and re-signs every account with
+// the new key. The previous key can be kept as a signing key for a grace period,
+// so servers holding account JWTs signed by it continue to trust them.
+func (os *Service) Rotate(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	opName := operatorName(fd)
+
+	op, err := GetOperator(ctx, req.Storage, opName)
+	if err != nil {
+		return nil, err
+	} else if op == nil {
+		return nil, errNoOperator
+	}
+
+	nk, err := nkutil.GetOrDefault(fd, "nkey", nkeys.CreateOperator)
+	if err != nil {
+		return nil, err
+	}
+
+	pubkey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	} else if !nkeys.IsValidPublicOperatorKey(pubkey) {
+		return nil, errors.New("nkey must be an operator nkey")
+	}
+
+	seed, err := nk.Seed()
+	if err != nil {
+		return nil, err
+	} else if string(seed) == op.Nkey {
+		return nil, errors.New("operator is already using the nkey")
+	}
+
+	prevNkey, err := nkeys.FromSeed([]byte(op.Nkey))
+	if err != nil {
+		return nil, err
+	}
+
+	prevPubkey, err := prevNkey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	retiredKey := ""
+	if grace := fd.Get("grace_period").(int); grace > 0 {
+		now := time.Now()
+		retiredKey = fmt.Sprintf("rotated-%d", now.UnixNano())
+		if _, ok := op.SigningKeys[retiredKey]; ok {
+			return nil, fmt.Errorf("signing key already exists: %s", retiredKey)
+		}
+
+		if op.SigningKeys == nil {
+			op.SigningKeys = make(map[string]string)
+		}
+		op.SigningKeys[retiredKey] = op.Nkey
+
+		if op.SigningKeyExpirations == nil {
+			op.SigningKeyExpirations = make(map[string]int64)
+		}
+		op.SigningKeyExpirations[retiredKey] = now.Add(time.Duration(grace) * time.Second).Unix()
+	}
+
+	op.Nkey = string(seed)
+
+	if err := putOperator(ctx, req.Storage, opName, op); err != nil {
+		return nil, err
+	}
+
+	res, err := operatorResponse(op)
+	if err != nil {
+		return nil, err
+	}
+	res.Data["previous_public_key"] = prevPubkey
+	if retiredKey != "" {
+		res.Data["retired_signing_key"] = retiredKey
+	}
+
+	return os.withReissuedAccounts(ctx, req.Storage, opName, res)
+}
+
+// withReissuedAccounts re-signs the accounts bound to an operator after the
+// operator has changed, and reports them in the response. Which key signs an
+// account depends on the operator's signing keys and strict signing key usage,
+// so their JWTs may no longer be valid otherwise.
+func (os *Service) withReissuedAccounts(ctx context.Context, s logical.Storage, opName string, res *logical.Response) (*logical.Response, error) {
+	accounts, err := os.Accounts.ReissueAccounts(ctx, s, opName)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Data["accounts"] = accounts
+	return res, nil
+}
+
+// ExpireSigningKeys removes signing keys that were retained after a rotation
+// once their grace period has passed.
+func (os *Service) ExpireSigningKeys(ctx context.Context, req *logical.Request) error {
+	opNames, err := req.Storage.List(ctx, "operators/")
+	if err != nil {
+		return err
+	}
+
+	for _, opName := range append([]string{""}, opNames...) {
+		op, err := GetOperator(ctx, req.Storage, opName)
+		if err != nil {
+			os.Log.Warn("failed to read operator", "operator", opName, "error", err)
+			continue
+		} else if op == nil {
+			continue
+		}
+
+		now := time.Now().Unix()
+		expired := false
+		for name, expiry := range op.SigningKeyExpirations {
+			if expiry <= now {
+				delete(op.SigningKeys, name)
+				delete(op.SigningKeyExpirations, name)
+				expired = true
+			}
+		}
+
+		if !expired {
+			continue
+		}
+
+		if err := putOperator(ctx, req.Storage, opName, op); err != nil {
+			os.Log.Warn("failed to expire operator signing keys", "operator", opName, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// ReissueExpiringJwts re-signs operator JWTs that are close to expiring
+func (os *Service) ReissueExpiringJwts(ctx context.Context, req *logical.Request) error {
+	opNames, err := req.Storage.List(ctx, "operators/")
+	if err != nil {
+		return err
+	}
+
+	for _, opName := range append([]string{""}, opNames...) {
+		op, err := GetOperator(ctx, req.Storage, opName)
+		if err != nil {
+			os.Log.Warn("failed to read operator", "operator", opName, "error", err)
+			continue
+		} else if op == nil || op.JwtTtl == 0 || op.Jwt == "" {
+			continue
+		}
+
+		previous := op.Jwt
+		if err := issueJwt(op); err != nil {
+			os.Log.Warn("failed to reissue operator JWT", "operator", opName, "error", err)
+			continue
+		} else if op.Jwt == previous {
+			continue
+		}
+
+		if err := putOperator(ctx, req.Storage, opName, op); err != nil {
+			os.Log.Warn("failed to reissue operator JWT", "operator", opName, "error", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	resolverMemory = "memory"
+	resolverFull   = "full"
+	resolverUrl    = "url"
+)
+
+// ServerConfig renders the operator mode section of a nats-server configuration,
+// which trusts the operator and resolves the accounts it has issued.
+func (os *Service) ServerConfig(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	opName := operatorName(fd)
+
+	op, err := GetOperator(ctx, req.Storage, opName)
+	if err != nil {
+		return nil, err
+	} else if op == nil {
+		return nil, errNoOperator
+	}
+
+	cfg := map[string]interface{}{
+		"operator": op.Jwt,
+	}
+
+	if op.SystemAccount != "" {
+		cfg["system_account"] = op.SystemAccount
+	}
+
+	switch resolver := strings.ToLower(fd.Get("resolver").(string)); resolver {
+	case resolverMemory, resolverFull:
+		if resolver == resolverMemory {
+			cfg["resolver"] = "MEMORY"
+		} else {
+			cfg["resolver"] = map[string]interface{}{
+				"type":         "full",
+				"dir":          fd.Get("resolver_dir").(string),
+				"allow_delete": fd.Get("allow_delete").(bool),
+				"interval":     fd.Get("interval").(string),
+			}
+		}
+
+		preload, err := os.Accounts.AccountJwts(ctx, req.Storage, opName)
+		if err != nil {
+			return nil, err
+		}
+		cfg["resolver_preload"] = preload
+	case resolverUrl:
+		url := fd.Get("resolver_url").(string)
+		if url == "" && op.AccountServerUrl != "" {
+			url = strings.TrimRight(op.AccountServerUrl, "/") + "/accounts/"
+		} else if url == "" {
+			return nil, fmt.Errorf("a resolver_url or the operator's account_server_url is required for the %s resolver", resolverUrl)
+		}
+		cfg["resolver"] = fmt.Sprintf("URL(%s)", url)
+	default:
+		return nil, fmt.Errorf("unsupported resolver: %s", resolver)
+	}
+
+	var config string
+	switch format := strings.ToLower(fd.Get("format").(string)); format {
+	case "conf":
+		config = renderConf(cfg)
+	case "json":
+		raw, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		config = string(raw)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"config": config,
+		},
+	}, nil
+}
+
+// renderConf writes the configuration in the nats-server configuration format
+func renderConf(cfg map[string]interface{}) string {
+	sb := new(strings.Builder)
+	writeConf(sb, cfg, 0)
+	return sb.String()
+}
+
+func writeConf(sb *strings.Builder, cfg map[string]interface{}, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	for _, k := range sortedKeys(cfg) {
+		switch v := cfg[k].(type) {
+		case map[string]interface{}:
+			fmt.Fprintf(sb, "%s%s: {\n", indent, k)
+			writeConf(sb, v, depth+1)
+			fmt.Fprintf(sb, "%s}\n", indent)
+		case map[string]string:
+			nested := make(map[string]interface{}, len(v))
+			for nk, nv := range v {
+				nested[nk] = nv
+			}
+			fmt.Fprintf(sb, "%s%s: {\n", indent, k)
+			writeConf(sb, nested, depth+1)
+			fmt.Fprintf(sb, "%s}\n", indent)
+		case string:
+			// MEMORY and URL(...) resolvers are keywords, rather than strings
+			if k == "resolver" {
+				fmt.Fprintf(sb, "%s%s: %s\n", indent, k, v)
+			} else {
+				fmt.Fprintf(sb, "%s%s: %q\n", indent, k, v)
+			}
+		default:
+			fmt.Fprintf(sb, "%s%s: %v\n", indent, k, v)
+		}
+	}
+}
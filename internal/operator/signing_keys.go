@@ -0,0 +1,179 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/egoodhall/vault-secrets-engine-nats/internal/nkutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/nkeys"
+)
+
+func (os *Service) ListSigningKeys(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	opName := operatorName(fd)
+
+	op, err := GetOperator(ctx, req.Storage, opName)
+	if err != nil {
+		return nil, err
+	} else if op == nil {
+		return nil, errNoOperator
+	}
+
+	return logical.ListResponse(sortedKeys(op.SigningKeys)), nil
+}
+
+// WriteSigningKey adds a signing key to the operator, and returns the updated
+// operator JWT, which will include the signing key's public key.
+func (os *Service) WriteSigningKey(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	opName := operatorName(fd)
+
+	name := fd.Get("name").(string)
+	if name == "" {
+		return nil, errors.New("signing key cannot have empty name")
+	}
+
+	op, err := GetOperator(ctx, req.Storage, opName)
+	if err != nil {
+		return nil, err
+	} else if op == nil {
+		return nil, errNoOperator
+	}
+
+	nk, err := nkutil.GetOrDefault(fd, "nkey", func() (nkeys.KeyPair, error) {
+		if seed, ok := op.SigningKeys[name]; ok {
+			return nkeys.FromSeed([]byte(seed))
+		}
+		return nkeys.CreateOperator()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pubkey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	} else if !nkeys.IsValidPublicOperatorKey(pubkey) {
+		return nil, errors.New("signing key must be an operator nkey")
+	}
+
+	seed, err := nk.Seed()
+	if err != nil {
+		return nil, err
+	}
+
+	if op.SigningKeys == nil {
+		op.SigningKeys = make(map[string]string)
+	}
+	op.SigningKeys[name] = string(seed)
+	delete(op.SigningKeyExpirations, name)
+
+	// Importing the seed of an external signing key moves it under management
+	external := op.ExternalSigningKeys[:0]
+	for _, sk := range op.ExternalSigningKeys {
+		if sk != pubkey {
+			external = append(external, sk)
+		}
+	}
+	op.ExternalSigningKeys = external
+
+	if err := putOperator(ctx, req.Storage, opName, op); err != nil {
+		return nil, err
+	}
+
+	return os.withReissuedAccounts(ctx, req.Storage, opName, &logical.Response{
+		Data: map[string]interface{}{
+			"name":         name,
+			"public_key":   pubkey,
+			"operator_jwt": op.Jwt,
+		},
+	})
+}
+
+func (os *Service) ReadSigningKey(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	opName := operatorName(fd)
+
+	name := fd.Get("name").(string)
+
+	op, err := GetOperator(ctx, req.Storage, opName)
+	if err != nil {
+		return nil, err
+	} else if op == nil {
+		return nil, errNoOperator
+	}
+
+	seed, ok := op.SigningKeys[name]
+	if !ok {
+		return nil, nil
+	}
+
+	nk, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return nil, err
+	}
+
+	pubkey, err := nk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":       name,
+			"public_key": pubkey,
+		},
+	}, nil
+}
+
+// DeleteSigningKey removes a signing key from the operator. Signing keys can
+// only be deleted once no accounts are issued by them.
+func (os *Service) DeleteSigningKey(ctx context.Context, req *logical.Request, fd *framework.FieldData) (*logical.Response, error) {
+	opName := operatorName(fd)
+
+	name := fd.Get("name").(string)
+
+	op, err := GetOperator(ctx, req.Storage, opName)
+	if err != nil {
+		return nil, err
+	} else if op == nil {
+		return nil, errNoOperator
+	}
+
+	if _, ok := op.SigningKeys[name]; !ok {
+		return nil, nil
+	} else if op.StrictSigningKeys && len(op.SigningKeys) == 1 {
+		return nil, errors.New("cannot delete the last signing key while strict signing key usage is enabled")
+	}
+
+	accounts, err := os.Accounts.SigningKeyAccounts(ctx, req.Storage, opName, name)
+	if err != nil {
+		return nil, err
+	} else if len(accounts) > 0 {
+		return nil, fmt.Errorf("operator signing key %s still issues accounts: %s", name, strings.Join(accounts, ", "))
+	}
+	delete(op.SigningKeys, name)
+	delete(op.SigningKeyExpirations, name)
+
+	if err := putOperator(ctx, req.Storage, opName, op); err != nil {
+		return nil, err
+	}
+
+	return os.withReissuedAccounts(ctx, req.Storage, opName, &logical.Response{
+		Data: map[string]interface{}{
+			"operator_jwt": op.Jwt,
+		},
+	})
+}
+
+// sortedKeys returns the keys of the map in sorted order
+func sortedKeys[K ~string, V any](m map[K]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	return keys
+}
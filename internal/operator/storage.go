@@ -2,19 +2,73 @@ package operator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/nats-io/nkeys"
 )
 
-const storagePath = "operator"
+// The default operator is stored at "operator", and any additional
+// operators are stored under "operators/"
+func storagePath(name string) string {
+	if name == "" {
+		return "operator"
+	}
+	return "operators/" + name
+}
+
+var errNoOperator = errors.New("operator has not been configured")
 
 type Operator struct {
-	Nkey string `json:"nkey"`
+	Name        string            `json:"name,omitempty"`
+	Nkey        string            `json:"nkey"`
+	Jwt         string            `json:"jwt,omitempty"`
+	SigningKeys map[string]string `json:"signing_keys,omitempty"`
+
+	// ExternalSigningKeys are public signing keys whose seeds aren't managed by the mount
+	ExternalSigningKeys []string `json:"external_signing_keys,omitempty"`
+
+	// SigningKeyExpirations holds the time at which retired signing keys are removed
+	SigningKeyExpirations map[string]int64 `json:"signing_key_expirations,omitempty"`
+
+	// StrictSigningKeys prevents the identity key from issuing account JWTs
+	StrictSigningKeys bool `json:"strict_signing_keys,omitempty"`
+
+	OperatorServiceUrls []string `json:"operator_service_urls,omitempty"`
+	AccountServerUrl    string   `json:"account_server_url,omitempty"`
+	SystemAccount       string   `json:"system_account,omitempty"`
+	AssertServerVersion string   `json:"assert_server_version,omitempty"`
+	Tags                []string `json:"tags,omitempty"`
+	JwtTtl              int      `json:"jwt_ttl,omitempty"`
+
+	// Audience and NotBefore are kept from imported operator JWTs
+	Audience  string `json:"audience,omitempty"`
+	NotBefore int64  `json:"not_before,omitempty"`
 }
 
-func GetOperator(ctx context.Context, s logical.Storage) (*Operator, error) {
-	entry, err := s.Get(ctx, storagePath)
+// AccountSigner returns the key pair that should be used to sign account JWTs.
+// If no signing key name is given, the operator's identity key is used, unless
+// the operator requires strict signing key usage.
+func (op *Operator) AccountSigner(signingKey string) (nkeys.KeyPair, error) {
+	if signingKey == "" && op.StrictSigningKeys {
+		return nil, errors.New("operator requires account JWTs to be issued by a signing key")
+	} else if signingKey == "" {
+		return nkeys.FromSeed([]byte(op.Nkey))
+	}
+
+	seed, ok := op.SigningKeys[signingKey]
+	if !ok {
+		return nil, fmt.Errorf("operator signing key not found: %s", signingKey)
+	}
+
+	return nkeys.FromSeed([]byte(seed))
+}
+
+// GetOperator reads the named operator from storage. An empty name refers
+// to the mount's default operator.
+func GetOperator(ctx context.Context, s logical.Storage, name string) (*Operator, error) {
+	entry, err := s.Get(ctx, storagePath(name))
 	if err != nil {
 		return nil, err
 	}
@@ -30,3 +84,50 @@ func GetOperator(ctx context.Context, s logical.Storage) (*Operator, error) {
 
 	return config, nil
 }
+
+// PublicKey returns the public key of the operator's identity key
+func (op *Operator) PublicKey() (string, error) {
+	nk, err := nkeys.FromSeed([]byte(op.Nkey))
+	if err != nil {
+		return "", err
+	}
+	return nk.PublicKey()
+}
+
+// defaultName is the name of the default operator, unless it's given another
+const defaultName = "default"
+
+// putOperator issues the operator's JWT and persists the operator. Operators
+// are named after the path they're stored at, unless they're given a name.
+func putOperator(ctx context.Context, s logical.Storage, name string, op *Operator) error {
+	if op.Name == "" && name == "" {
+		op.Name = defaultName
+	} else if op.Name == "" {
+		op.Name = name
+	}
+
+	if err := issueJwt(op); err != nil {
+		return err
+	}
+
+	if e, err := logical.StorageEntryJSON(storagePath(name), op); err != nil {
+		return err
+	} else if err := s.Put(ctx, e); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetSystemAccount records the public key of the operator's system account
+func SetSystemAccount(ctx context.Context, s logical.Storage, name, pubKey string) error {
+	op, err := GetOperator(ctx, s, name)
+	if err != nil {
+		return err
+	} else if op == nil {
+		return errNoOperator
+	}
+
+	op.SystemAccount = pubKey
+	return putOperator(ctx, s, name, op)
+}